@@ -0,0 +1,56 @@
+package lrucache
+
+import "sync/atomic"
+
+// WithApproximateLRU makes Get and GetLocal find values with only the read lock held, so concurrent readers
+// do not block each other. Instead of being moved to the head of the queue, a found entry is marked as accessed.
+// When space is needed, marked entries at the end of the queue are moved to the head, and unmarked,
+// instead of being evicted, as in the CLOCK (second chance) algorithm.
+// An entry accessed since it last reached the end of the queue is still never evicted before the ones not accessed,
+// but accessed entries are ordered by when they were moved rather than when they were accessed, so the order
+// is not strict LRU. EvictionPreview does not take the marks into account.
+// Lookups still take the write lock if WithAutoGrow, WithMissCounts, WithPromotionThreshold, WithTinyLFU, WithMaxIdle,
+// WithEntryRemovedHits or a policy other than PolicyLRU is used, or if the entry found has expired.
+func WithApproximateLRU[K comparable, V any]() Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.approximate = true
+	}
+}
+
+// getShared looks up key with the read lock held, for WithApproximateLRU.
+// done is false if the lookup must be done with the write lock held instead.
+func (cache *LruCache[K, V]) getShared(key K) (value V, ok, done bool) {
+	if cache.autoGrow != nil || cache.missCounts != nil || cache.promotionThreshold > 0 || cache.policy != PolicyLRU || cache.tinyLFU != nil || cache.maxIdle > 0 ||
+		cache.entryRemovedHits != nil {
+		return
+	}
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	element := cache.m[key]
+	if element == nil {
+		cache.stats.misses.Add(1)
+		return value, false, true
+	}
+	entry := element.Value.(*entry[K, V])
+	if cache.expired(entry) {
+		return
+	}
+	if atomic.LoadUint32(&entry.accessed) == 0 {
+		atomic.StoreUint32(&entry.accessed, 1)
+	}
+	cache.stats.hits.Add(1)
+	return entry.v, true, true
+}
+
+// giveSecondChances moves the accessed entries at the end of the queue to the head, for WithApproximateLRU.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) giveSecondChances() {
+	for back := cache.l.Back(); back != nil; back = cache.l.Back() {
+		entry := back.Value.(*entry[K, V])
+		if atomic.LoadUint32(&entry.accessed) == 0 {
+			return
+		}
+		atomic.StoreUint32(&entry.accessed, 0)
+		cache.l.MoveToFront(back)
+	}
+}
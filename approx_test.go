@@ -0,0 +1,80 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"sync"
+	"testing"
+)
+
+func TestApproximateLRU(t *testing.T) {
+	cache := lrucache.New(3, nil, lrucache.WithApproximateLRU[int, int]())
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	cache.Put(3, 3)
+	if value, ok := cache.Get(1); !ok || value != 1 {
+		t.Fatalf("Wrong value returned by LruCache.Get. 1, true expected, but %v, %v returned", value, ok)
+	}
+	cache.Put(4, 4) // 1 is given a second chance, evicts 2.
+	for key, expected := range map[int]bool{1: true, 2: false, 3: true, 4: true} {
+		if _, ok := cache.Peek(key); ok != expected {
+			t.Fatalf("Wrong value returned by LruCache.Peek(%v). %v expected, but %v returned", key, expected, ok)
+		}
+	}
+	cache.Put(5, 5) // Evicts 3.
+	cache.Put(6, 6) // Evicts 4.
+	cache.Put(7, 7) // Evicts 1, not accessed since its second chance.
+	if _, ok := cache.Peek(1); ok {
+		t.Fatal("Wrong value returned by LruCache.Peek. Nothing expected")
+	}
+}
+
+// TestApproximateLRUReaders runs the readers copying entries with the read lock held while Get marks entries
+// as accessed with the same lock held. Meant to be run with -race.
+func TestApproximateLRUReaders(t *testing.T) {
+	cache := lrucache.New(100, nil, lrucache.WithApproximateLRU[int, int](),
+		lrucache.WithMemorySampler[int, int](func(key, value int) uint { return 8 }, 10))
+	for i := 0; i < 100; i++ {
+		cache.Put(i, i)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			cache.Get(i % 100)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if n := len(cache.View().Keys()); n != 100 {
+			t.Errorf("Wrong number of keys returned by View.Keys. 100 expected, but %v returned", n)
+		}
+		if memory := cache.EstimatedMemory(); memory != 800 {
+			t.Errorf("Wrong value returned by LruCache.EstimatedMemory. 800 expected, but %v returned", memory)
+		}
+		if n := cache.Clone().Len(); n != 100 {
+			t.Errorf("Wrong value returned by LruCache.Len of the clone. 100 expected, but %v returned", n)
+		}
+	}
+	wg.Wait()
+}
+
+// benchmarkGetParallel reads a small hot set from many goroutines.
+func benchmarkGetParallel(b *testing.B, options ...lrucache.Option[int, int]) {
+	cache := lrucache.New(1000, nil, options...)
+	for i := 0; i < 1000; i++ {
+		cache.Put(i, i)
+	}
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			cache.Get(i % 16)
+		}
+	})
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	benchmarkGetParallel(b)
+}
+
+func BenchmarkGetParallelApproximateLRU(b *testing.B) {
+	benchmarkGetParallel(b, lrucache.WithApproximateLRU[int, int]())
+}
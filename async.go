@@ -0,0 +1,50 @@
+package lrucache
+
+import "sync/atomic"
+
+// asyncCallbacks is the queue of WithAsyncCallbacks.
+type asyncCallbacks[K comparable, V any] struct {
+	queue   chan []removal[K, V]
+	pending atomic.Int64 // The number of removal batches sent or being sent, and not yet delivered.
+}
+
+// WithAsyncCallbacks makes the cache call the EntryRemoved, EntryRemovedReason and EntryRemovedHits functions,
+// the per-entry functions of GetEnsureWithRemoved, and send to the channels returned by Events, on a worker goroutine
+// instead of the goroutine of Put, Remove etc., so slow callbacks do not slow down the writers.
+// The removals of a call are queued at once, and delivered in the order they were queued.
+// The queue holds the removals of at most queueSize calls. When it is full, the call waits for the worker
+// to make room, so slow callbacks eventually slow down the writers rather than being dropped
+// or letting the queue grow without bound. It follows that callbacks which remove entries of the cache,
+// e.g. by putting, can wait for themselves once the queue is full, and must not be used with this option.
+// The worker is started when the queue becomes non-empty, and returns when it becomes empty again.
+// A callback panic not recovered by WithCallbackPanicHandler crashes the program.
+// queueSize must be positive.
+func WithAsyncCallbacks[K comparable, V any](queueSize int) Option[K, V] {
+	if queueSize <= 0 {
+		panic("Invalid callback queue size")
+	}
+	return func(cache *LruCache[K, V]) {
+		cache.asyncCallbacks = &asyncCallbacks[K, V]{queue: make(chan []removal[K, V], queueSize)}
+	}
+}
+
+// enqueue queues removals for the worker, starting it if not running.
+func (cache *LruCache[K, V]) enqueue(removals []removal[K, V]) {
+	async := cache.asyncCallbacks
+	if async.pending.Add(1) == 1 {
+		// The previous worker, if any, has delivered everything and is returning.
+		go cache.deliverQueued()
+	}
+	async.queue <- removals
+}
+
+// deliverQueued delivers the queued removals until the queue is empty.
+func (cache *LruCache[K, V]) deliverQueued() {
+	async := cache.asyncCallbacks
+	for {
+		cache.deliver(<-async.queue)
+		if async.pending.Add(-1) == 0 {
+			return
+		}
+	}
+}
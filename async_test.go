@@ -0,0 +1,39 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAsyncCallbacks(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	removed := make(chan int, 100)
+	cache := lrucache.New(10, func(key, oldValue, newValue int) {
+		time.Sleep(delay) // Closing a connection, say.
+		removed <- key
+	}, lrucache.WithAsyncCallbacks[int, int](4))
+	for i := 0; i < 10; i++ {
+		cache.Put(i, i)
+	}
+	start := time.Now()
+	cache.PutSize(100, 100, 10) // Evicts all the others.
+	if elapsed := time.Since(start); elapsed >= 10*delay/2 {
+		t.Fatalf("PutSize waited for the callbacks. Less than %v expected, but %v took", 10*delay/2, elapsed)
+	}
+	cache.Remove(100)
+
+	var keys []int
+	for len(keys) < 11 {
+		select {
+		case key := <-removed:
+			keys = append(keys, key)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Callbacks not called. 11 removals expected, but %v got", keys)
+		}
+	}
+	if expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 100}; !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("Wrong removed keys. %v expected, but %v got", expected, keys)
+	}
+}
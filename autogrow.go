@@ -0,0 +1,49 @@
+package lrucache
+
+import "time"
+
+// autoGrow is the state of WithAutoGrow.
+type autoGrow struct {
+	missThreshold float64
+	step, ceiling uint
+	window        time.Duration
+	start         time.Time // Start of the current window.
+	hits, misses  uint
+}
+
+// WithAutoGrow makes the cache grow its maximum size by step, up to ceiling, when the miss rate of lookups
+// exceeds missThreshold over a window.
+// Lookups are counted by Get, GetEnsure and GetEnsureAsync. The miss rate of a window is checked by the
+// first lookup after the window has elapsed, and a new window starts then, so an idle cache never grows.
+// The maximum size never shrinks back.
+func WithAutoGrow[K comparable, V any](missThreshold float64, step, ceiling uint, window time.Duration) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.autoGrow = &autoGrow{missThreshold: missThreshold, step: step, ceiling: ceiling, window: window}
+	}
+}
+
+// growOnMisses counts a lookup for WithAutoGrow. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) growOnMisses(hit bool) {
+	grow := cache.autoGrow
+	if hit {
+		grow.hits++
+	} else {
+		grow.misses++
+	}
+	now := cache.now()
+	if grow.start.IsZero() {
+		grow.start = now
+	}
+	if now.Sub(grow.start) < grow.window {
+		return
+	}
+	if float64(grow.misses)/float64(grow.hits+grow.misses) > grow.missThreshold && cache.maxSize < grow.ceiling {
+		if cache.maxSize+grow.step > grow.ceiling || cache.maxSize+grow.step < cache.maxSize {
+			cache.maxSize = grow.ceiling
+		} else {
+			cache.maxSize += grow.step
+		}
+	}
+	grow.start = now
+	grow.hits, grow.misses = 0, 0
+}
@@ -0,0 +1,29 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"testing"
+	"time"
+)
+
+func TestAutoGrow(t *testing.T) {
+	const window = 10 * time.Millisecond
+	cache := lrucache.New(2, nil, lrucache.WithAutoGrow[int, int](0.5, 2, 5, window))
+	cache.Put(1, 1)
+	cache.Get(1)
+	cache.Get(2)
+	time.Sleep(window)
+	cache.Get(1) // Miss rate 1/3.
+	if maxSize := cache.MaxSize(); maxSize != 2 {
+		t.Fatalf("Wrong value returned by LruCache.MaxSize. 2 expected, but %v returned", maxSize)
+	}
+	for i := 0; i < 2; i++ {
+		cache.Get(2)
+		cache.Get(3)
+		time.Sleep(window)
+		cache.Get(1) // Miss rate 2/3.
+	}
+	if maxSize := cache.MaxSize(); maxSize != 5 {
+		t.Fatalf("Wrong value returned by LruCache.MaxSize. 5 expected, but %v returned", maxSize)
+	}
+}
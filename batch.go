@@ -0,0 +1,184 @@
+package lrucache
+
+// OpKind is the kind of an Op.
+type OpKind int
+
+const (
+	// OpPut puts Op.Value with Op.Size for Op.Key, like PutSize.
+	OpPut OpKind = iota
+	// OpRemove removes the entry for Op.Key, like Remove.
+	OpRemove
+	// OpClear removes all entries.
+	OpClear
+)
+
+// Op is an operation applied by ApplyBatch.
+type Op[K comparable, V any] struct {
+	Kind  OpKind
+	Key   K
+	Value V
+	Size  uint
+}
+
+// Result is the outcome of an Op applied by ApplyBatch.
+type Result[K comparable, V any] struct {
+	// OldValue is the value replaced by OpPut or removed by OpRemove, or the zero value if none.
+	OldValue V
+	// OK is whether there is an OldValue.
+	OK bool
+	// Removed is the keys of the other entries removed by the operation: evicted to make space for OpPut,
+	// removed by OpClear, or depending on a removed entry (see PutWithDeps).
+	Removed []K
+}
+
+// ApplyBatch applies ops in order with the mutex locked once, so no other goroutine sees the cache in the middle
+// of the batch. All operations are applied; there is no partial failure. The result of ops[i] is results[i].
+// The EntryRemoved function is called after the whole batch has been applied and the mutex unlocked,
+// in the order the entries were removed.
+// ApplyBatch panics without applying any operation if the Kind of an Op is invalid.
+func (cache *LruCache[K, V]) ApplyBatch(ops []Op[K, V]) (results []Result[K, V]) {
+	for _, op := range ops {
+		switch op.Kind {
+		case OpPut, OpRemove:
+			cache.validateKey(op.Key)
+		case OpClear:
+		default:
+			panic("Invalid OpKind")
+		}
+	}
+	cache.operations.Add(uint64(len(ops)))
+	results = make([]Result[K, V], len(ops))
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	for i, op := range ops {
+		result := &results[i]
+		switch op.Kind {
+		case OpPut:
+			oldValue, replaced, putRemovals := cache.putSize(op.Key, op.Value, op.Size, 0)
+			result.OldValue, result.OK = oldValue, replaced
+			for j, removal := range putRemovals {
+				if j > 0 || !replaced {
+					result.Removed = append(result.Removed, removal.key)
+				}
+			}
+			removals = append(removals, putRemovals...)
+		case OpRemove:
+			// An expired entry is removed as expired by the lookup, as Remove does.
+			element, expired := cache.lookup(op.Key)
+			for _, removal := range expired {
+				if removal.key != op.Key {
+					result.Removed = append(result.Removed, removal.key)
+				}
+			}
+			removals = append(removals, expired...)
+			if element != nil {
+				removed := cache.evict(element, ReasonRemoved)
+				result.OldValue, result.OK = removed[0].oldValue, true
+				for _, removal := range removed[1:] {
+					result.Removed = append(result.Removed, removal.key)
+				}
+				removals = append(removals, removed...)
+			}
+		case OpClear:
+			for cache.l.Len() > 0 {
+				for _, removal := range cache.evict(cache.l.Back(), ReasonCleared) {
+					result.Removed = append(result.Removed, removal.key)
+					removals = append(removals, removal)
+				}
+			}
+		}
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// PutMulti does the same work as calling Put for each entry of entries, in no particular order,
+// except the mutex is locked once and the cache is trimmed to maxSize once, after all entries have been put.
+// The EntryRemoved function is called after the mutex has been unlocked, for the replaced values
+// and the evicted entries.
+func (cache *LruCache[K, V]) PutMulti(entries map[K]V) {
+	// The sizes are computed before locking the mutex, as Put does, so the WithSizer function may access the cache.
+	sizes := make(map[K]uint, len(entries))
+	for key, value := range entries {
+		cache.validateKey(key)
+		sizes[key] = cache.sizeOf(key, value)
+	}
+	cache.operations.Add(1)
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	for key, value := range entries {
+		_, _, placed := cache.place(key, value, sizes[key], 0)
+		removals = append(removals, placed...)
+	}
+	removals = append(removals, cache.trim()...)
+	cache.mutex.Unlock()
+	cache.notify(removals)
+}
+
+// PutSizeMulti does the same work as calling PutSize for each of entries in order, except the mutex is locked once
+// and the cache is trimmed to maxSize once, after all entries have been put.
+// The EntryRemoved function is called after the mutex has been unlocked, for the replaced values
+// and the evicted entries.
+func (cache *LruCache[K, V]) PutSizeMulti(entries []Entry[K, V]) {
+	for i := range entries {
+		cache.validateKey(entries[i].Key)
+	}
+	cache.operations.Add(1)
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	for i := range entries {
+		_, _, placed := cache.place(entries[i].Key, entries[i].Value, entries[i].Size, 0)
+		removals = append(removals, placed...)
+	}
+	removals = append(removals, cache.trim()...)
+	cache.mutex.Unlock()
+	cache.notify(removals)
+}
+
+// GetMulti finds the values of keys with the mutex locked once.
+// Found entries are promoted as if Get were called for each of them, and their values are returned in a map.
+// Keys not found are absent from the returned map.
+func (cache *LruCache[K, V]) GetMulti(keys []K) map[K]V {
+	for _, key := range keys {
+		cache.validateKey(key)
+	}
+	cache.operations.Add(1)
+	result := make(map[K]V, len(keys))
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	defer func() {
+		cache.mutex.Unlock()
+		cache.notify(removals)
+	}()
+	for _, key := range keys {
+		element, expired := cache.lookup(key)
+		removals = append(removals, expired...)
+		if element != nil {
+			result[key] = element.Value.(*entry[K, V]).v
+			cache.hit(element)
+		}
+		cache.lookedUp(key, element != nil)
+	}
+	return result
+}
+
+// Merge puts the entries of other into the cache with their sizes, from the least recently used to the most recently used,
+// as PutSizeMulti does, so the merged entries keep their relative order and become more recently used than the entries
+// of the cache. The entries of other are copied with its read lock held, which is released before the mutex of the cache
+// is locked, so merging caches into each other concurrently can't deadlock. Expired entries are not merged.
+// Merging a cache into itself does nothing.
+func (cache *LruCache[K, V]) Merge(other *LruCache[K, V]) {
+	if other == cache {
+		return
+	}
+	other.mutex.RLock()
+	entries := make([]Entry[K, V], 0, other.l.Len())
+	for element := other.l.Back(); element != nil; element = element.Prev() {
+		if entry := element.Value.(*entry[K, V]); !other.expired(entry) {
+			entries = append(entries, Entry[K, V]{entry.k, entry.v, entry.size})
+		}
+	}
+	other.mutex.RUnlock()
+	cache.PutSizeMulti(entries)
+}
@@ -0,0 +1,211 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestApplyBatch(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(2, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	cache.Put(0, 0)
+	results := cache.ApplyBatch([]lrucache.Op[int, int]{
+		{Kind: lrucache.OpClear},
+		{Kind: lrucache.OpPut, Key: 1, Value: 1, Size: 1},
+		{Kind: lrucache.OpPut, Key: 2, Value: 2, Size: 1},
+		{Kind: lrucache.OpPut, Key: 1, Value: 10, Size: 1},
+		{Kind: lrucache.OpPut, Key: 3, Value: 3, Size: 1},
+		{Kind: lrucache.OpRemove, Key: 1},
+		{Kind: lrucache.OpRemove, Key: 4},
+	})
+	expected := []lrucache.Result[int, int]{
+		{Removed: []int{0}},
+		{},
+		{},
+		{OldValue: 1, OK: true},
+		{Removed: []int{2}},
+		{OldValue: 10, OK: true},
+		{},
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("Wrong value returned by LruCache.ApplyBatch. %v expected, but %v returned", expected, results)
+	}
+	if !reflect.DeepEqual(removed, []int{0, 1, 2, 1}) {
+		t.Fatalf("Wrong removed keys. [0 1 2 1] expected, but %v got", removed)
+	}
+	if value, _ := cache.Get(3); value != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Get. 3 expected, but %v returned", value)
+	}
+	if size := cache.Size(); size != 1 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 1 expected, but %v returned", size)
+	}
+}
+
+func TestApplyBatchSizeOverflow(t *testing.T) {
+	cache := lrucache.New[string, int](math.MaxUint, nil)
+	cache.PutSize("a", 1, 10)
+	cache.PutSize("b", 2, 10)
+	results := cache.ApplyBatch([]lrucache.Op[string, int]{
+		{Kind: lrucache.OpPut, Key: "b", Value: 20, Size: math.MaxUint - 5}, // Evicts "a" first.
+	})
+	expected := []lrucache.Result[string, int]{{OldValue: 2, OK: true, Removed: []string{"a"}}}
+	if !reflect.DeepEqual(results, expected) {
+		t.Fatalf("Wrong value returned by LruCache.ApplyBatch. %v expected, but %v returned", expected, results)
+	}
+}
+
+func TestApplyBatchRemoveExpired(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var reasons []lrucache.Reason
+	cache := lrucache.New(10, nil, lrucache.WithClock[string, int](clock.Now),
+		lrucache.WithEntryRemovedReason(func(key string, oldValue, newValue int, reason lrucache.Reason) {
+			reasons = append(reasons, reason)
+		}))
+	cache.PutWithTTL("a", 1, 1, time.Minute)
+	clock.Advance(time.Minute)
+	results := cache.ApplyBatch([]lrucache.Op[string, int]{{Kind: lrucache.OpRemove, Key: "a"}})
+	if expected := []lrucache.Result[string, int]{{}}; !reflect.DeepEqual(results, expected) {
+		t.Fatalf("Wrong value returned by LruCache.ApplyBatch. %v expected, but %v returned", expected, results)
+	}
+	if !reflect.DeepEqual(reasons, []lrucache.Reason{lrucache.ReasonExpired}) {
+		t.Fatalf("Wrong removal reasons. [expired] expected, but %v got", reasons)
+	}
+}
+
+func TestApplyBatchInvalidOp(t *testing.T) {
+	cache := lrucache.New[int, int](2, nil)
+	cache.Put(0, 0)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("LruCache.ApplyBatch should panic")
+			}
+		}()
+		cache.ApplyBatch([]lrucache.Op[int, int]{
+			{Kind: lrucache.OpClear},
+			{Kind: lrucache.OpPut, Key: 1, Value: 1, Size: 1},
+			{Kind: lrucache.OpKind(100)},
+		})
+	}()
+	// Nothing applied.
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{0}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [0] expected, but %v returned", keys)
+	}
+	cache.Put(1, 1) // Not deadlocked.
+}
+
+func TestPutSizeMulti(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(4, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	cache.Put(0, 0)
+	cache.Put(1, 1)
+	cache.PutSizeMulti([]lrucache.Entry[int, int]{{Key: 1, Value: 10, Size: 1}, {Key: 2, Value: 2, Size: 2}, {Key: 3, Value: 3, Size: 1}})
+	if !reflect.DeepEqual(removed, []int{1, 0}) {
+		t.Fatalf("Wrong removed keys. [1 0] expected, but %v got", removed)
+	}
+	cache.PutMulti(map[int]int{4: 4}) // Evicts 1.
+	expected := map[int]int{2: 2, 3: 3, 4: 4}
+	if values := cache.GetMulti([]int{1, 2, 3, 4}); !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Wrong value returned by LruCache.GetMulti. %v expected, but %v returned", expected, values)
+	}
+}
+
+func BenchmarkPutLoop(b *testing.B) {
+	cache := lrucache.New[int, int](1000, nil)
+	for i := 0; i < b.N; i++ {
+		for key := 0; key < 100; key++ {
+			cache.Put(i*100+key, key)
+		}
+	}
+}
+
+func BenchmarkPutSizeMulti(b *testing.B) {
+	cache := lrucache.New[int, int](1000, nil)
+	entries := make([]lrucache.Entry[int, int], 100)
+	for i := 0; i < b.N; i++ {
+		for key := range entries {
+			entries[key] = lrucache.Entry[int, int]{Key: i*100 + key, Value: key, Size: 1}
+		}
+		cache.PutSizeMulti(entries)
+	}
+}
+
+func BenchmarkGetLoop(b *testing.B) {
+	cache := lrucache.New[int, int](1000, nil)
+	for key := 0; key < 100; key++ {
+		cache.Put(key, key)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for key := 0; key < 100; key++ {
+			cache.Get(key)
+		}
+	}
+}
+
+func BenchmarkGetMulti(b *testing.B) {
+	cache := lrucache.New[int, int](1000, nil)
+	keys := make([]int, 100)
+	for key := range keys {
+		cache.Put(key, key)
+		keys[key] = key
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.GetMulti(keys)
+	}
+}
+
+func TestPutMultiSizer(t *testing.T) {
+	var cache *lrucache.LruCache[string, string]
+	cache = lrucache.New(10, nil, lrucache.WithSizer(func(key, value string) uint {
+		if cache.Contains(key) { // Not deadlocked.
+			return 1
+		}
+		return uint(len(value))
+	}))
+	cache.Put("a", "aaa")
+	cache.PutMulti(map[string]string{"a": "aaaa", "b": "bb"})
+	if size := cache.Size(); size != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 3 expected, but %v returned", size)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	var removed []string
+	cache := lrucache.New(5, func(key string, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Put("c", 3)
+	other := lrucache.New[string, int](10, nil)
+	other.Put("d", 4)
+	other.PutSize("b", 20, 2)
+	other.Put("e", 5)
+	other.Get("d")
+	cache.Merge(other)
+	if !reflect.DeepEqual(removed, []string{"b", "a"}) {
+		t.Fatalf("Wrong removed keys. [b a] expected, but %v got", removed)
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"d", "e", "b", "c"}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [d e b c] expected, but %v returned", keys)
+	}
+	if size := cache.Size(); size != 5 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 5 expected, but %v returned", size)
+	}
+	if n := other.Len(); n != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Len of other. 3 expected, but %v returned", n)
+	}
+	cache.Merge(cache)
+	if !reflect.DeepEqual(removed, []string{"b", "a"}) {
+		t.Fatalf("Wrong removed keys. [b a] expected, but %v got", removed)
+	}
+}
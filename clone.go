@@ -0,0 +1,136 @@
+package lrucache
+
+import (
+	"container/list"
+	"maps"
+	"sync/atomic"
+)
+
+// Clone returns an independent copy of the cache, taken with the read lock held: the same entries in the same order,
+// with the same maximum size, callbacks and options. Values are shared, not copied.
+// Later changes of either cache are not seen by the other.
+// Counters start from zero in the copy: Stats, OperationCount, EvictionAgeStats and MissCounts.
+// Pending creates of GetEnsure, channels returned by Events, per-entry functions passed to GetEnsureWithRemoved
+// and the sweeper started by StartSweeper are not copied.
+func (cache *LruCache[K, V]) Clone() *LruCache[K, V] {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+
+	clone := &LruCache[K, V]{
+		m:                    make(map[K]*list.Element, max(len(cache.m), cache.expectedEntries)),
+		l:                    list.New(),
+		maxSize:              cache.maxSize,
+		size:                 cache.size,
+		countOnly:            cache.countOnly,
+		entryRemoved:         cache.entryRemoved,
+		entryRemovedReason:   cache.entryRemovedReason,
+		entryRemovedHits:     cache.entryRemovedHits,
+		callbackPanicHandler: cache.callbackPanicHandler,
+		valuePool:            cache.valuePool,
+		sizer:                cache.sizer,
+		memorySizer:          cache.memorySizer,
+		memorySamples:        cache.memorySamples,
+		promotionThreshold:   cache.promotionThreshold,
+		evictionVeto:         cache.evictionVeto,
+		expectedEntries:      cache.expectedEntries,
+		minResidency:         cache.minResidency,
+		readFallback:         cache.readFallback,
+		keyValidator:         cache.keyValidator,
+		prioritized:          cache.prioritized,
+		defaultTTL:           cache.defaultTTL,
+		maxIdle:              cache.maxIdle,
+		approximate:          cache.approximate,
+		policy:               cache.policy,
+		expiring:             cache.expiring,
+		collected:            cache.collected,
+		now:                  cache.now,
+	}
+	if cache.autoGrow != nil {
+		autoGrow := *cache.autoGrow
+		clone.autoGrow = &autoGrow
+	}
+	if cache.fairEviction != nil {
+		fair := *cache.fairEviction
+		fair.sizes = maps.Clone(fair.sizes)
+		clone.fairEviction = &fair
+	}
+	if cache.asyncCallbacks != nil {
+		WithAsyncCallbacks[K, V](cap(cache.asyncCallbacks.queue))(clone)
+	}
+	if cache.missCounts != nil {
+		WithMissCounts[K, V](cache.missCounts.counts.MaxSize())(clone)
+	}
+	if cache.tinyLFU != nil {
+		sketch := *cache.tinyLFU
+		for i := range sketch.rows {
+			sketch.rows[i] = append([]uint8(nil), sketch.rows[i]...)
+		}
+		clone.tinyLFU = &sketch
+	}
+	if cache.dependents != nil {
+		clone.dependents = make(map[K]map[K]struct{}, len(cache.dependents))
+		for key, dependents := range cache.dependents {
+			clone.dependents[key] = maps.Clone(dependents)
+		}
+		clone.dependencies = make(map[K][]K, len(cache.dependencies))
+		for key, dependencies := range cache.dependencies {
+			clone.dependencies[key] = append([]K(nil), dependencies...)
+		}
+	}
+
+	// The elements of clone for the elements of cache.
+	elements := make(map[*list.Element]*list.Element, len(cache.m))
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		// Built field by field: accessed is written with the read lock held, see WithApproximateLRU,
+		// and the per-entry functions and the places in the policy structures are not copied.
+		from := element.Value.(*entry[K, V])
+		entry := &entry[K, V]{
+			k:             from.k,
+			v:             from.v,
+			size:          from.size,
+			hits:          from.hits,
+			created:       from.created,
+			prefix:        from.prefix,
+			priority:      from.priority,
+			expires:       from.expires,
+			used:          from.used,
+			accessed:      atomic.LoadUint32(&from.accessed),
+			inA1in:        from.inA1in,
+			negative:      from.negative,
+			probationary:  from.probationary,
+			probationHits: from.probationHits,
+		}
+		elements[element] = clone.l.PushBack(entry)
+		clone.m[entry.k] = elements[element]
+	}
+	clone.probation, clone.protectedSize = elements[cache.probation], cache.protectedSize
+	switch cache.policy {
+	case PolicyLFU:
+		clone.lfu = list.New()
+		for bucket := cache.lfu.Front(); bucket != nil; bucket = bucket.Next() {
+			cloneBucket := clone.lfu.PushBack(&lfuBucket{freq: bucket.Value.(*lfuBucket).freq, elements: list.New()})
+			for element := bucket.Value.(*lfuBucket).elements.Front(); element != nil; element = element.Next() {
+				cloneElement := elements[element.Value.(*list.Element)]
+				entry := cloneElement.Value.(*entry[K, V])
+				entry.lfuBucket = cloneBucket
+				entry.policyElement = cloneBucket.Value.(*lfuBucket).elements.PushBack(cloneElement)
+			}
+		}
+	case Policy2Q:
+		q := cache.twoQ
+		cloneQ := &twoQ[K]{a1in: list.New(), am: list.New(), inSize: q.inSize, a1out: list.New(), a1outKeys: make(map[K]*list.Element, len(q.a1outKeys))}
+		for _, queues := range [][2]*list.List{{q.a1in, cloneQ.a1in}, {q.am, cloneQ.am}} {
+			for element := queues[0].Front(); element != nil; element = element.Next() {
+				cloneElement := elements[element.Value.(*list.Element)]
+				cloneElement.Value.(*entry[K, V]).policyElement = queues[1].PushBack(cloneElement)
+			}
+		}
+		for element := q.a1out.Front(); element != nil; element = element.Next() {
+			key := element.Value.(K)
+			cloneQ.a1outKeys[key] = cloneQ.a1out.PushBack(key)
+		}
+		clone.twoQ = cloneQ
+	}
+	clone.publishSize()
+	return clone
+}
@@ -0,0 +1,51 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"reflect"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(3, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	cache.Put(1, 10)
+	cache.PutSize(2, 20, 2)
+	cache.Get(1)
+	clone := cache.Clone()
+
+	cache.Put(3, 30) // Evicts 2.
+	cache.Put(1, 11)
+	if !reflect.DeepEqual(removed, []int{2, 1}) {
+		t.Fatalf("Wrong removed keys. [2 1] expected, but %v got", removed)
+	}
+	if keys, values := clone.Keys(), clone.Values(); !reflect.DeepEqual(keys, []int{1, 2}) || !reflect.DeepEqual(values, []int{10, 20}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys and LruCache.Values of clone. [1 2], [10 20] expected, but %v, %v returned", keys, values)
+	}
+	if size, maxSize := clone.Size(), clone.MaxSize(); size != 3 || maxSize != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Size and LruCache.MaxSize of clone. 3, 3 expected, but %v, %v returned", size, maxSize)
+	}
+
+	removed = nil
+	clone.Put(4, 40) // Evicts 2, calling the same EntryRemoved function.
+	if !reflect.DeepEqual(removed, []int{2}) {
+		t.Fatalf("Wrong removed keys. [2] expected, but %v got", removed)
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{1, 3}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [1 3] expected, but %v returned", keys)
+	}
+}
+
+func TestClonePolicyLFU(t *testing.T) {
+	cache := lrucache.New(2, nil, lrucache.WithPolicy[int, int](lrucache.PolicyLFU))
+	cache.Put(1, 1)
+	cache.Get(1)
+	cache.Put(2, 2)
+	clone := cache.Clone()
+	clone.Put(3, 3) // Evicts 2, used less than 1.
+	if keys := clone.Keys(); !reflect.DeepEqual(keys, []int{3, 1}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [3 1] expected, but %v returned", keys)
+	}
+}
@@ -0,0 +1,56 @@
+package lrucache
+
+import "container/list"
+
+// WithCollected lets the cache hold values which the garbage collector may reclaim, such as weak pointers
+// (weak.Pointer of Go 1.24), so that large values are not pinned in memory by the cache alone.
+// collected reports whether a value has been reclaimed, for example
+//
+//	func(p weak.Pointer[Image]) bool { return p.Value() == nil }
+//
+// An entry whose value has been collected is treated like an expired one: Get and the other getting methods
+// report a miss, so GetEnsure creates the value again, and the entry is removed, with ReasonCollected,
+// when found or when space is needed, releasing its size. Use RemoveCollected to remove all such entries at once,
+// for example from a cleanup function registered with runtime.AddCleanup.
+// collected is called with the mutex held, so it must be fast and must not access the cache.
+func WithCollected[K comparable, V any](collected func(value V) bool) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.collected = collected
+		cache.expiring = true
+	}
+}
+
+// expiredReason returns the reason of removing the expired entry, ReasonCollected or ReasonExpired.
+func (cache *LruCache[K, V]) expiredReason(entry *entry[K, V]) Reason {
+	if cache.collected != nil && cache.collected(entry.v) {
+		return ReasonCollected
+	}
+	return ReasonExpired
+}
+
+// RemoveCollected removes the entries whose values have been collected, see WithCollected,
+// and returns the number of entries removed, including those depending on them (see PutWithDeps).
+// The EntryRemoved function is called for them after the mutex has been unlocked.
+func (cache *LruCache[K, V]) RemoveCollected() int {
+	if cache.collected == nil {
+		return 0
+	}
+	cache.operations.Add(1)
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	var matched []*list.Element
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		if cache.collected(element.Value.(*entry[K, V]).v) {
+			matched = append(matched, element)
+		}
+	}
+	for _, element := range matched {
+		// Already removed if it depends on another collected entry.
+		if cache.m[element.Value.(*entry[K, V]).k] == element {
+			removals = append(removals, cache.evict(element, ReasonCollected)...)
+		}
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return len(removals)
+}
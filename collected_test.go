@@ -0,0 +1,52 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"reflect"
+	"testing"
+)
+
+// ref is a reference whose value can be reclaimed explicitly, standing for a weak pointer.
+type ref struct {
+	value *string
+}
+
+func newRef(value string) ref {
+	return ref{&value}
+}
+
+func (r ref) collect() {
+	*r.value = ""
+}
+
+func TestCollected(t *testing.T) {
+	var removed []string
+	cache := lrucache.New(10, nil, lrucache.WithCollected[string, ref](func(value ref) bool { return *value.value == "" }),
+		lrucache.WithEntryRemovedReason(func(key string, oldValue, newValue ref, reason lrucache.Reason) {
+			removed = append(removed, key+":"+reason.String())
+		}))
+	a, b, c := newRef("a"), newRef("b"), newRef("c")
+	cache.PutSize("a", a, 3)
+	cache.PutSize("b", b, 3)
+	cache.PutSize("c", c, 3)
+
+	a.collect()
+	if value := cache.GetEnsure("a", func(key string) (ref, uint) { return newRef("A"), 3 }); *value.value != "A" {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsure. \"A\" expected, but %q returned", *value.value)
+	}
+	b.collect()
+	if cache.Contains("b") {
+		t.Fatal("Wrong value returned by LruCache.Contains. false expected for collected value")
+	}
+	cache.PutSize("d", newRef("d"), 3) // Removes the collected "b" rather than the least recently used "c".
+	c.collect()
+	if n := cache.RemoveCollected(); n != 1 {
+		t.Fatalf("Wrong value returned by LruCache.RemoveCollected. 1 expected, but %v returned", n)
+	}
+	if !reflect.DeepEqual(removed, []string{"a:collected", "b:collected", "c:collected"}) {
+		t.Fatalf("Wrong removed keys. [a:collected b:collected c:collected] expected, but %v got", removed)
+	}
+	if size := cache.Size(); size != 6 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 6 expected, but %v returned", size)
+	}
+}
@@ -0,0 +1,24 @@
+package lrucache
+
+// maxCompositeParts is the maximum number of parts of a composite key.
+const maxCompositeParts = 4
+
+// Composite is a key made of parts. See CompositeKey.
+type Composite struct {
+	n     int
+	parts [maxCompositeParts]any // Unused parts are nil.
+}
+
+// CompositeKey returns a key made of parts, such as CompositeKey(userID, resourceType), which can be used
+// directly as a key of a LruCache[Composite, V]. Two composite keys are equal if they have the same number of parts and
+// the parts are equal pairwise, without formatting the parts into a string.
+// All parts must be comparable, like map keys, or the cache panics when the key is used.
+// At most 4 parts are supported.
+func CompositeKey(parts ...any) Composite {
+	if len(parts) > maxCompositeParts {
+		panic("Too many parts of composite key")
+	}
+	key := Composite{n: len(parts)}
+	copy(key.parts[:], parts)
+	return key
+}
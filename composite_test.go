@@ -0,0 +1,39 @@
+package lrucache_test
+
+import (
+	"fmt"
+	"github.com/mkch/lrucache"
+	"testing"
+)
+
+func TestCompositeKey(t *testing.T) {
+	cache := lrucache.New[lrucache.Composite, string](10, nil)
+	cache.Put(lrucache.CompositeKey(1, "photo"), "1/photo")
+	cache.Put(lrucache.CompositeKey(1, "photo", nil), "1/photo/nil")
+	cache.Put(lrucache.CompositeKey(2, "photo"), "2/photo")
+	if value, _ := cache.Get(lrucache.CompositeKey(1, "photo")); value != "1/photo" {
+		t.Fatalf("Wrong value returned by LruCache.Get. \"1/photo\" expected, but %v returned", value)
+	}
+	if value, ok := cache.Get(lrucache.CompositeKey(1, "video")); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+	if size := cache.Size(); size != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 3 expected, but %v returned", size)
+	}
+}
+
+var cacheForBenchmarkCompositeKey = lrucache.New[lrucache.Composite, int](100, nil)
+
+var cacheForBenchmarkSprintfKey = lrucache.New[string, int](100, nil)
+
+func BenchmarkGetCompositeKey(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		cacheForBenchmarkCompositeKey.Get(lrucache.CompositeKey(i%100, "photo"))
+	}
+}
+
+func BenchmarkGetSprintfKey(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		cacheForBenchmarkSprintfKey.Get(fmt.Sprintf("%v/%v", i%100, "photo"))
+	}
+}
@@ -0,0 +1,77 @@
+package lrucache
+
+import (
+	"context"
+	"errors"
+)
+
+// errAbandoned is the error of a GetEnsureCtx create whose callers have all given up.
+var errAbandoned = errors.New("lrucache: create abandoned by all callers")
+
+// GetEnsureCtx does the same work as GetEnsureErr except the callers can give up waiting by canceling ctx,
+// in which case ctx.Err() is returned. If ctx is already done, ctx.Err() is returned without looking up key.
+// create is called in a new goroutine with a context which carries the values of ctx and is canceled
+// only when all the callers of GetEnsureCtx waiting for it have given up, not when one of them, including
+// the first one, does. Callers still waiting get the value. If all have given up,
+// nothing is cached even if create returns a value.
+// The callers of GetEnsure, GetEnsureErr and GetEnsureAsync can share the create too, but do not keep it alive,
+// and call create themselves if it is abandoned.
+func (cache *LruCache[K, V]) GetEnsureCtx(ctx context.Context, key K, create func(ctx context.Context, key K) (value V, size uint, err error)) (value V, err error) {
+	if err = ctx.Err(); err != nil {
+		return
+	}
+	var ok bool
+	if value, ok = cache.Get(key); ok {
+		return
+	}
+
+	cache.mutex.Lock()
+	element, expired := cache.lookup(key)
+	if element != nil {
+		// Cached meanwhile.
+		value = element.Value.(*entry[K, V]).v
+		cache.mutex.Unlock()
+		return
+	}
+	flight := cache.filling[key]
+	if flight == nil {
+		flight = cache.startFill(key)
+		fillCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		flight.cancel = cancel
+		go cache.fill(key, func(key K) (value V, size uint, err error) {
+			defer cancel()
+			value, size, err = create(fillCtx, key)
+			if fillCtx.Err() != nil {
+				var zero V
+				return zero, 0, errAbandoned
+			}
+			return
+		}, flight)
+	} else {
+		flight.joined++
+	}
+	if flight.cancel != nil {
+		flight.waiters++
+	}
+	cache.mutex.Unlock()
+	cache.notify(expired)
+
+	select {
+	case <-flight.done:
+		if flight.err == errAbandoned || (!flight.ok && flight.err == nil) {
+			// Abandoned before this call joined, or create panicked.
+			return cache.GetEnsureCtx(ctx, key, create)
+		}
+		return flight.value, flight.err
+	case <-ctx.Done():
+		if flight.cancel != nil {
+			cache.mutex.Lock()
+			if flight.waiters--; flight.waiters == 0 {
+				flight.cancel()
+			}
+			cache.mutex.Unlock()
+		}
+		var zero V
+		return zero, ctx.Err()
+	}
+}
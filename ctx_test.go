@@ -0,0 +1,81 @@
+package lrucache_test
+
+import (
+	"context"
+	"github.com/mkch/lrucache"
+	"testing"
+	"time"
+)
+
+func TestGetEnsureCtxCanceled(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := cache.GetEnsureCtx(ctx, 1, func(ctx context.Context, key int) (int, uint, error) {
+		t.Fatal("create should not be called with a canceled context")
+		return 0, 0, nil
+	}); err != context.Canceled {
+		t.Fatalf("Wrong error returned by LruCache.GetEnsureCtx. %v expected, but %v returned", context.Canceled, err)
+	}
+
+	// The only caller gives up during create.
+	ctx, cancel = context.WithCancel(context.Background())
+	created := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := cache.GetEnsureCtx(ctx, 1, func(ctx context.Context, key int) (int, uint, error) {
+		defer close(created)
+		<-ctx.Done()
+		return 10, 1, nil
+	}); err != context.Canceled {
+		t.Fatalf("Wrong error returned by LruCache.GetEnsureCtx. %v expected, but %v returned", context.Canceled, err)
+	}
+	<-created
+	if value, err := cache.GetEnsureCtx(context.Background(), 1, func(ctx context.Context, key int) (int, uint, error) {
+		return 20, 1, nil
+	}); err != nil || value != 20 {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsureCtx. 20, <nil> expected, but %v, %v returned", value, err)
+	}
+}
+
+func TestGetEnsureCtxFollower(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	started := make(chan struct{})
+	leaderErr := make(chan error)
+	go func() {
+		_, err := cache.GetEnsureCtx(leaderCtx, 1, func(ctx context.Context, key int) (int, uint, error) {
+			close(started)
+			<-release
+			return 10, 1, ctx.Err()
+		})
+		leaderErr <- err
+	}()
+	<-started
+	type result struct {
+		value int
+		err   error
+	}
+	follower := make(chan result)
+	go func() {
+		value, err := cache.GetEnsureCtx(context.Background(), 1, func(ctx context.Context, key int) (int, uint, error) {
+			return 20, 1, nil
+		})
+		follower <- result{value, err}
+	}()
+	waitJoined(cache, 1, 1)
+	cancelLeader()
+	if err := <-leaderErr; err != context.Canceled {
+		t.Fatalf("Wrong error returned by LruCache.GetEnsureCtx. %v expected, but %v returned", context.Canceled, err)
+	}
+	close(release)
+	if r := <-follower; r.err != nil || r.value != 10 {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsureCtx. 10, <nil> expected, but %v, %v returned", r.value, r.err)
+	}
+	if value, ok := cache.Get(1); !ok || value != 10 {
+		t.Fatalf("Wrong value returned by LruCache.Get. 10, true expected, but %v, %v returned", value, ok)
+	}
+}
@@ -0,0 +1,62 @@
+package lrucache
+
+// PutWithDeps does similar work as PutSize except the entry depends on the entries of the keys in dependsOn,
+// cached or not. Whenever an entry leaves the cache, because it is removed, evicted or replaced by a new value,
+// all entries depending on it, directly or indirectly, are removed as well, and the EntryRemoved function
+// is called for each of them with the zero newValue.
+// Dependencies form a graph which may contain cycles. Each entry is removed at most once, so a cycle stops the cascade.
+// Replacing an entry with PutSize or PutWithDeps drops the dependencies of the old entry.
+func (cache *LruCache[K, V]) PutWithDeps(key K, value V, size uint, dependsOn ...K) (oldValue V, replaced bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	oldValue, replaced, removals = cache.putSize(key, value, size, 0)
+	if _, exists := cache.m[key]; exists {
+		for _, source := range dependsOn {
+			if source == key {
+				continue
+			}
+			if cache.dependents == nil {
+				cache.dependents = make(map[K]map[K]struct{})
+				cache.dependencies = make(map[K][]K)
+			}
+			dependents := cache.dependents[source]
+			if dependents == nil {
+				dependents = make(map[K]struct{})
+				cache.dependents[source] = dependents
+			}
+			dependents[key] = struct{}{}
+			cache.dependencies[key] = append(cache.dependencies[key], source)
+		}
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// removeDependents forgets the dependencies of key, whose entry has left the cache or been replaced,
+// and removes the entries depending on it.
+func (cache *LruCache[K, V]) removeDependents(key K) (removals []removal[K, V]) {
+	if cache.dependents == nil {
+		return
+	}
+	for _, source := range cache.dependencies[key] {
+		if dependents := cache.dependents[source]; dependents != nil {
+			delete(dependents, key)
+			if len(dependents) == 0 {
+				delete(cache.dependents, source)
+			}
+		}
+	}
+	delete(cache.dependencies, key)
+	dependents := cache.dependents[key]
+	delete(cache.dependents, key)
+	for dependent := range dependents {
+		// Already removed if there is a cycle.
+		if element := cache.m[dependent]; element != nil {
+			removals = append(removals, cache.evict(element, ReasonRemoved)...)
+		}
+	}
+	return
+}
@@ -0,0 +1,89 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"testing"
+)
+
+func TestPutWithDeps(t *testing.T) {
+	var removed []string
+	cache := lrucache.New(10, func(key string, oldValue, newValue int) {
+		if newValue == 0 {
+			removed = append(removed, key)
+		}
+	})
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.PutWithDeps("sum", 3, 1, "a", "b")
+	cache.PutWithDeps("double-sum", 6, 1, "sum")
+	cache.Put("c", 3)
+
+	cache.Put("a", 10) // Replaced, invalidates "sum" and "double-sum".
+	for _, key := range []string{"sum", "double-sum"} {
+		if value, ok := cache.Get(key); ok {
+			t.Fatalf("Wrong value returned by LruCache.Get(%q). Nothing expected, but %v returned", key, value)
+		}
+	}
+	if len(removed) != 2 {
+		t.Fatalf("Wrong removed keys. [sum double-sum] expected, but %v got", removed)
+	}
+	if size := cache.Size(); size != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 3 expected, but %v returned", size)
+	}
+
+	// Cycle.
+	removed = nil
+	cache.PutWithDeps("x", 1, 1, "y")
+	cache.PutWithDeps("y", 2, 1, "x", "c")
+	if value, _ := cache.Remove("c"); value != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Remove. 3 expected, but %v returned", value)
+	}
+	if len(removed) != 3 || removed[0] != "c" {
+		t.Fatalf("Wrong removed keys. [c y x] expected, but %v got", removed)
+	}
+	if size := cache.Size(); size != 2 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 2 expected, but %v returned", size)
+	}
+}
+
+func TestPutWithDepsEvicted(t *testing.T) {
+	cache := lrucache.New[string, int](3, nil)
+	cache.Put("a", 1)
+	cache.PutWithDeps("b", 2, 1, "a")
+	cache.Put("c", 3)
+	cache.Put("d", 4) // Evicts "a" and then "b".
+	if size := cache.Size(); size != 2 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 2 expected, but %v returned", size)
+	}
+	if value, ok := cache.Get("b"); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+}
+
+func TestGetAndGrowWithDeps(t *testing.T) {
+	var removed []string
+	cache := lrucache.New(10, func(key string, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	cache.Put("src", 1)
+	cache.PutWithDeps("agg", 10, 1, "src")
+	grow := func(value int) (int, uint) { return value + 1, 2 }
+	cache.GetAndGrow("src", grow) // Not a replacement. Keeps "agg".
+	if value, ok := cache.Get("agg"); !ok || value != 10 {
+		t.Fatalf("Wrong value returned by LruCache.Get(\"agg\"). 10, true expected, but %v, %v returned", value, ok)
+	}
+	cache.GetAndGrow("agg", grow) // Still depends on "src".
+	if removed != nil {
+		t.Fatalf("Wrong removed keys. [] expected, but %v got", removed)
+	}
+	if replacements := cache.Stats().Replacements; replacements != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Stats. 0 replacements expected, but %v returned", replacements)
+	}
+	cache.Remove("src")
+	if cache.Contains("agg") {
+		t.Fatal("Wrong value returned by LruCache.Contains(\"agg\"). false expected after its dependency is removed")
+	}
+	if size := cache.Size(); size != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 0 expected, but %v returned", size)
+	}
+}
@@ -0,0 +1,54 @@
+package lrucache
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dumpValueLimit is the maximum number of runes of a value formatted by Dump.
+const dumpValueLimit = 40
+
+// Dump writes a human-readable description of the cache to w for debugging: a header line with the size,
+// the maximum size and the number of entries, followed by a line for each entry from the most recently used
+// to the least recently used, with its key, size and value formatted by fmt, truncated if long.
+// Expired entries are marked. The entries are copied with the read lock held, and written to w without holding the lock.
+func (cache *LruCache[K, V]) Dump(w io.Writer) error {
+	type dumpEntry struct {
+		Entry[K, V]
+		expired bool
+	}
+	cache.mutex.RLock()
+	size, maxSize := cache.size, cache.maxSize
+	entries := make([]dumpEntry, 0, cache.l.Len())
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*entry[K, V])
+		entries = append(entries, dumpEntry{Entry[K, V]{entry.k, entry.v, entry.size}, cache.expired(entry)})
+	}
+	cache.mutex.RUnlock()
+
+	if _, err := fmt.Fprintf(w, "LruCache size=%v/%v len=%v\n", size, maxSize, len(entries)); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		value := []rune(fmt.Sprint(entry.Value))
+		if len(value) > dumpValueLimit {
+			value = append(value[:dumpValueLimit-3], []rune("...")...)
+		}
+		expired := ""
+		if entry.expired {
+			expired = " expired"
+		}
+		if _, err := fmt.Fprintf(w, "  %v (size %v%v): %v\n", entry.Key, entry.Size, expired, string(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String returns the output of Dump.
+func (cache *LruCache[K, V]) String() string {
+	var b strings.Builder
+	cache.Dump(&b)
+	return b.String()
+}
@@ -0,0 +1,23 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	cache := lrucache.New[string, string](10, nil)
+	cache.Put("a", "1")
+	cache.PutSize("b", strings.Repeat("x", 50), 3)
+	cache.Put("c", "3")
+	cache.Get("a")
+	expected := `LruCache size=5/10 len=3
+  a (size 1): 1
+  c (size 1): 3
+  b (size 3): ` + strings.Repeat("x", 37) + `...
+`
+	if str := cache.String(); str != expected {
+		t.Fatalf("Wrong value returned by LruCache.String. %q expected, but %q returned", expected, str)
+	}
+}
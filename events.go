@@ -0,0 +1,111 @@
+package lrucache
+
+import "sync"
+
+// RemovalEvent is a value which left the cache, sent to the channels returned by Events.
+type RemovalEvent[K comparable, V any] struct {
+	Key    K
+	Value  V // The old value.
+	Reason Reason
+}
+
+// FullPolicy is what to do with a RemovalEvent when the channel returned by Events is full.
+type FullPolicy int
+
+const (
+	// Block waits until the event can be sent, so a slow receiver slows down the goroutines removing entries.
+	Block FullPolicy = iota
+	// DropOldest discards the oldest event in the channel to make room, so the receiver may miss events.
+	// The buffer of the channel must not be 0.
+	DropOldest
+)
+
+// subscription is a channel returned by Events.
+type subscription[K comparable, V any] struct {
+	c      chan RemovalEvent[K, V]
+	policy FullPolicy
+	done   chan struct{} // Closed to stop blocked sends.
+	mutex  sync.Mutex    // Serializes sends and close of c.
+	closed bool
+}
+
+// subscriptions are the subscriptions of a cache.
+type subscriptions[K comparable, V any] struct {
+	mutex sync.Mutex
+	list  []*subscription[K, V]
+}
+
+// Events returns a channel with a buffer of size buffer, which receives a RemovalEvent for each value
+// leaving the cache for any reason, in addition to the calls of the EntryRemoved function.
+// Events are sent by the goroutine removing the value after the mutex of the cache has been unlocked,
+// and policy decides what to do when the channel is full.
+// unsubscribe stops sending events and closes the channel. It must be called when the channel is no longer received from,
+// or blocked senders of policy Block never return.
+// Events panics if policy is DropOldest and buffer is 0, as there would never be an old event to drop.
+func (cache *LruCache[K, V]) Events(buffer int, policy FullPolicy) (events <-chan RemovalEvent[K, V], unsubscribe func()) {
+	if policy == DropOldest && buffer == 0 {
+		panic("Invalid event buffer size")
+	}
+	sub := &subscription[K, V]{c: make(chan RemovalEvent[K, V], buffer), policy: policy, done: make(chan struct{})}
+	cache.subscriptions.mutex.Lock()
+	cache.subscriptions.list = append(cache.subscriptions.list, sub)
+	cache.subscriptions.mutex.Unlock()
+
+	var once sync.Once
+	return sub.c, func() {
+		once.Do(func() {
+			cache.subscriptions.mutex.Lock()
+			for i, s := range cache.subscriptions.list {
+				if s == sub {
+					cache.subscriptions.list = append(cache.subscriptions.list[:i:i], cache.subscriptions.list[i+1:]...)
+					break
+				}
+			}
+			cache.subscriptions.mutex.Unlock()
+			close(sub.done)
+			sub.mutex.Lock()
+			sub.closed = true
+			close(sub.c)
+			sub.mutex.Unlock()
+		})
+	}
+}
+
+// publish sends the events of removals to the channels returned by Events.
+// Must be called without holding the mutex.
+func (cache *LruCache[K, V]) publish(removals []removal[K, V]) {
+	cache.subscriptions.mutex.Lock()
+	subs := cache.subscriptions.list
+	cache.subscriptions.mutex.Unlock()
+	for _, sub := range subs {
+		sub.mutex.Lock()
+		for i := 0; i < len(removals) && !sub.closed; i++ {
+			sub.send(RemovalEvent[K, V]{removals[i].key, removals[i].oldValue, removals[i].reason})
+		}
+		sub.mutex.Unlock()
+	}
+}
+
+// send sends event according to the policy. Must be called with the mutex of sub locked.
+func (sub *subscription[K, V]) send(event RemovalEvent[K, V]) {
+	if sub.policy == Block {
+		select {
+		case sub.c <- event:
+		case <-sub.done:
+		}
+		return
+	}
+	for {
+		select {
+		case sub.c <- event:
+			return
+		default:
+		}
+		select {
+		case <-sub.c:
+		case <-sub.done:
+			return
+		default:
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"reflect"
+	"testing"
+)
+
+func TestEvents(t *testing.T) {
+	cache := lrucache.New[int, int](2, nil)
+	events, unsubscribe := cache.Events(10, lrucache.Block)
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	cache.Put(3, 3) // Evicts 1.
+	cache.Put(2, 20)
+	cache.Remove(3)
+	unsubscribe()
+	cache.Remove(2)
+	var got []lrucache.RemovalEvent[int, int]
+	for event := range events {
+		got = append(got, event)
+	}
+	expected := []lrucache.RemovalEvent[int, int]{
+		{Key: 1, Value: 1, Reason: lrucache.ReasonEvicted},
+		{Key: 2, Value: 2, Reason: lrucache.ReasonReplaced},
+		{Key: 3, Value: 3, Reason: lrucache.ReasonRemoved},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Wrong events. %v expected, but %v got", expected, got)
+	}
+	unsubscribe()
+}
+
+func TestEventsDropOldest(t *testing.T) {
+	cache := lrucache.New[int, int](1, nil)
+	events, unsubscribe := cache.Events(2, lrucache.DropOldest)
+	for i := 0; i < 5; i++ {
+		cache.Put(i, i) // Evicts i-1.
+	}
+	unsubscribe()
+	var keys []int
+	for event := range events {
+		keys = append(keys, event.Key)
+	}
+	if !reflect.DeepEqual(keys, []int{2, 3}) {
+		t.Fatalf("Wrong event keys. [2 3] expected, but %v got", keys)
+	}
+}
+
+func TestEventsUnsubscribeBlocked(t *testing.T) {
+	cache := lrucache.New[int, int](1, nil)
+	_, unsubscribe := cache.Events(0, lrucache.Block)
+	done := make(chan struct{})
+	go func() {
+		cache.Put(1, 1)
+		cache.Put(2, 2) // Blocks sending the eviction of 1.
+		close(done)
+	}()
+	unsubscribe()
+	<-done
+}
+
+func TestEventsDropOldestUnbuffered(t *testing.T) {
+	cache := lrucache.New[int, int](1, nil)
+	defer func() {
+		if recover() == nil {
+			t.Fatal("LruCache.Events should panic")
+		}
+	}()
+	cache.Events(0, lrucache.DropOldest)
+}
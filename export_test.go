@@ -0,0 +1,11 @@
+package lrucache
+
+// Joined returns the number of callers which joined the pending create for key, or 0 if there is none.
+func Joined[K comparable, V any](cache *LruCache[K, V], key K) int {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if flight := cache.filling[key]; flight != nil {
+		return flight.joined
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+package lrucache
+
+// fairEviction is the state of WithFairEviction.
+type fairEviction[K comparable] struct {
+	prefixOf func(key K) string
+	maxShare float64
+	sizes    map[string]uint // Sum of entry sizes of each prefix.
+}
+
+// WithFairEviction makes eviction prefer entries whose prefix, as returned by prefixOf, takes more than
+// maxSharePerPrefix of maxSize, such as tenant "a" of keys like "a:1", "a:2" in a multi-tenant cache.
+// The least recently used entry of an over-share prefix among the last 8 entries of the queue is evicted
+// first, otherwise eviction is as usual. This is approximate fairness to protect quiet prefixes from a noisy one,
+// not a hard quota: a prefix can exceed its share, e.g. when its entries are all recently used.
+// prefixOf is called once per entry added, with the mutex held, so it must be fast and must not access the cache.
+func WithFairEviction[K comparable, V any](prefixOf func(key K) string, maxSharePerPrefix float64) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.fairEviction = &fairEviction[K]{prefixOf: prefixOf, maxShare: maxSharePerPrefix, sizes: make(map[string]uint)}
+	}
+}
+
+// overShare returns whether prefix takes more than its share of maxSize.
+func (fair *fairEviction[K]) overShare(prefix string, maxSize uint) bool {
+	return float64(fair.sizes[prefix]) > fair.maxShare*float64(maxSize)
+}
+
+// removed updates the accounting after an entry of prefix and size left the cache.
+func (fair *fairEviction[K]) removed(prefix string, size uint) {
+	if size := fair.sizes[prefix] - size; size > 0 {
+		fair.sizes[prefix] = size
+	} else {
+		delete(fair.sizes, prefix)
+	}
+}
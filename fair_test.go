@@ -0,0 +1,52 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func tenantOf(key string) string {
+	return strings.SplitN(key, ":", 2)[0]
+}
+
+func TestFairEviction(t *testing.T) {
+	cache := lrucache.New(6, nil, lrucache.WithFairEviction[string, int](tenantOf, 0.5))
+	cache.Put("quiet:1", 1)
+	cache.Put("quiet:2", 2)
+	for i := 0; i < 10; i++ {
+		cache.Put("noisy:"+strconv.Itoa(i), i)
+	}
+	for _, key := range []string{"quiet:1", "quiet:2"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Fatalf("Wrong value returned by LruCache.Get(%q). Nothing returned", key)
+		}
+	}
+	if value, ok := cache.Get("noisy:5"); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+	if size := cache.Size(); size != 6 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 6 expected, but %v returned", size)
+	}
+}
+
+func benchmarkPutTenants(b *testing.B, options ...lrucache.Option[string, int]) {
+	cache := lrucache.New(1000, nil, options...)
+	keys := make([]string, 3000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i%7) + ":" + strconv.Itoa(i)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cache.Put(keys[i%len(keys)], i)
+	}
+}
+
+func BenchmarkPutTenants(b *testing.B) {
+	benchmarkPutTenants(b)
+}
+
+func BenchmarkPutTenantsFairEviction(b *testing.B) {
+	benchmarkPutTenants(b, lrucache.WithFairEviction[string, int](tenantOf, 0.2))
+}
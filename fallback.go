@@ -0,0 +1,45 @@
+package lrucache
+
+// ReadOnlyCache is a cache which can be read by WithReadFallback. LruCache implements it.
+type ReadOnlyCache[K comparable, V any] interface {
+	// GetLocal returns the value for key and true, or the zero value and false if no value is found,
+	// without consulting any other cache.
+	GetLocal(key K) (value V, ok bool)
+}
+
+// readFallback is the state of WithReadFallback.
+type readFallback[K comparable, V any] struct {
+	sibling ReadOnlyCache[K, V]
+	size    func(key K, value V) uint
+}
+
+// WithReadFallback makes Get and GetEnsure consult sibling on a miss. A value found in sibling is put into
+// this cache, with the entry size returned by size, and returned.
+// The sibling is only read, with GetLocal, and never written, so caches can fall back to each other
+// without cycles.
+func WithReadFallback[K comparable, V any](sibling ReadOnlyCache[K, V], size func(key K, value V) uint) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.readFallback = &readFallback[K, V]{sibling: sibling, size: size}
+	}
+}
+
+// getFallback returns the value for key read from the sibling cache and true, or the zero value and false if not found.
+// The value is also cached, unless a value for key has been put meanwhile, in which case that value is returned.
+func (cache *LruCache[K, V]) getFallback(key K) (value V, ok bool) {
+	if value, ok = cache.readFallback.sibling.GetLocal(key); !ok {
+		return
+	}
+	size := cache.readFallback.size(key, value)
+
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	if element != nil {
+		value = element.Value.(*entry[K, V]).v
+	} else {
+		_, _, putRemovals := cache.putSize(key, value, size, 0)
+		removals = append(removals, putRemovals...)
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
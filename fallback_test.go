@@ -0,0 +1,29 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"testing"
+)
+
+func TestReadFallback(t *testing.T) {
+	size := func(key int, value string) uint { return 2 }
+	east := lrucache.New[int, string](10, nil)
+	west := lrucache.New(10, nil, lrucache.WithReadFallback(east, size))
+
+	east.Put(1, "1")
+	if value, _ := west.Get(1); value != "1" {
+		t.Fatalf("Wrong value returned by LruCache.Get. \"1\" expected, but %v returned", value)
+	}
+	if size := west.Size(); size != 2 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 2 expected, but %v returned", size)
+	}
+	if value, ok := west.Get(2); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+	if value, ok := west.GetLocal(3); ok {
+		t.Fatalf("Wrong value returned by LruCache.GetLocal. Nothing expected, but %v returned", value)
+	}
+	if size := east.Size(); size != 1 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 1 expected, but %v returned", size)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/mkch/lrucache
+
+go 1.22
@@ -0,0 +1,56 @@
+package lrucache
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+// gobEntry is the gob form of an entry.
+type gobEntry[K comparable, V any] struct {
+	Key   K
+	Value V
+	Size  uint
+}
+
+// Save writes all entries to w with encoding/gob, keys, values, sizes and the recency order,
+// so that Load restores them. Keys and values must be encodable by gob: if K or V is an interface type,
+// the concrete types stored in it must be registered with gob.Register.
+// Expiry times and priorities are not saved.
+// The entries are copied with the read lock held, and encoded to w without holding the lock.
+func (cache *LruCache[K, V]) Save(w io.Writer) error {
+	cache.mutex.RLock()
+	entries := make([]gobEntry[K, V], 0, cache.l.Len())
+	for element := cache.l.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*entry[K, V])
+		entries = append(entries, gobEntry[K, V]{entry.k, entry.v, entry.size})
+	}
+	cache.mutex.RUnlock()
+	return gob.NewEncoder(w).Encode(entries)
+}
+
+// Load reads the entries written by Save from r and puts them into the cache with their sizes,
+// in the saved order, so the most recently used entry saved is the most recently used one after loading.
+// Entries already in the cache are kept, as less recently used than the loaded ones, unless replaced by them.
+// The cache is trimmed to maxSize once, after all entries have been put, and the EntryRemoved function
+// is called for the replaced and evicted entries after the mutex has been unlocked.
+// If r can't be decoded, an error is returned and the cache is unchanged.
+func (cache *LruCache[K, V]) Load(r io.Reader) error {
+	var entries []gobEntry[K, V]
+	if err := gob.NewDecoder(r).Decode(&entries); err != nil {
+		return err
+	}
+	for i := range entries {
+		cache.validateKey(entries[i].Key)
+	}
+	cache.operations.Add(1)
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	for i := range entries {
+		_, _, placed := cache.place(entries[i].Key, entries[i].Value, entries[i].Size, 0)
+		removals = append(removals, placed...)
+	}
+	removals = append(removals, cache.trim()...)
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return nil
+}
@@ -0,0 +1,51 @@
+package lrucache_test
+
+import (
+	"bytes"
+	"github.com/mkch/lrucache"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	cache := lrucache.New[string, int](10, nil)
+	cache.Put("a", 1)
+	cache.PutSize("b", 2, 3)
+	cache.Put("c", 3)
+	cache.Get("a")
+	var buf bytes.Buffer
+	if err := cache.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	saved := buf.Bytes()
+
+	loaded := lrucache.New[string, int](10, nil)
+	if err := loaded.Load(bytes.NewReader(saved)); err != nil {
+		t.Fatal(err)
+	}
+	if keys := loaded.Keys(); !reflect.DeepEqual(keys, []string{"a", "c", "b"}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [a c b] expected, but %v returned", keys)
+	}
+	if size := loaded.Size(); size != 5 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 5 expected, but %v returned", size)
+	}
+
+	var removed []string
+	small := lrucache.New(4, func(key string, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	if err := small.Load(bytes.NewReader(saved)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(removed, []string{"b"}) {
+		t.Fatalf("Wrong removed keys. [b] expected, but %v got", removed)
+	}
+
+	if err := small.Load(strings.NewReader("garbage")); err == nil {
+		t.Fatal("LruCache.Load should fail for invalid input")
+	}
+	if n := small.Len(); n != 2 {
+		t.Fatalf("Wrong value returned by LruCache.Len. 2 expected, but %v returned", n)
+	}
+}
@@ -0,0 +1,18 @@
+package lrucache
+
+// EntryRemovedHits is the function called for entries that have been removed, like EntryRemoved,
+// with the number of times the entry was found by Get, GetEnsure and the like while cached.
+type EntryRemovedHits[K comparable, V any] func(key K, oldValue, newValue V, hits uint64)
+
+// WithEntryRemovedHits makes the cache call entryRemoved every time an entry was removed, with its hits,
+// e.g. to tell the entries which were used a lot from those which were only churned through the cache.
+// The hits of an entry are counted from when it was added, and not reset when its value is replaced,
+// so the hits passed for a replaced value include those of the values it replaced.
+// A value discarded without being cached has 0 hits.
+// It is called right after the EntryRemovedReason function, if any.
+// Lookups take the write lock even if WithApproximateLRU is used, so that the hits are counted.
+func WithEntryRemovedHits[K comparable, V any](entryRemoved EntryRemovedHits[K, V]) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.entryRemovedHits = entryRemoved
+	}
+}
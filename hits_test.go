@@ -0,0 +1,30 @@
+package lrucache_test
+
+import (
+	"fmt"
+	"github.com/mkch/lrucache"
+	"reflect"
+	"testing"
+)
+
+func TestEntryRemovedHits(t *testing.T) {
+	var removed []string
+	cache := lrucache.New(2, nil, lrucache.WithEntryRemovedHits(func(key string, oldValue, newValue int, hits uint64) {
+		removed = append(removed, fmt.Sprintf("%v:%v", key, hits))
+	}))
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	for i := 0; i < 3; i++ {
+		cache.Get("a")
+	}
+	cache.GetEnsure("a", func(key string) (int, uint) { return 0, 1 })
+	cache.Peek("a") // Not counted.
+	cache.Put("a", 10)
+	cache.Get("a")
+	cache.Put("c", 3) // Evicts "b".
+	cache.Put("d", 4) // Evicts "a".
+	expected := []string{"a:4", "b:0", "a:5"}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("Wrong removals reported to EntryRemovedHits. %v expected, but %v got", expected, removed)
+	}
+}
@@ -0,0 +1,64 @@
+package lrucache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonlEntry is the JSON object of an entry in JSON Lines.
+type jsonlEntry[K comparable, V any] struct {
+	Key   K    `json:"key"`
+	Value V    `json:"value"`
+	Size  uint `json:"size"`
+}
+
+// ExportJSONL writes all entries to w in JSON Lines format, one {"key":...,"value":...,"size":...} object per line,
+// from the least recently used to the most recently used, so that ImportJSONL restores the order.
+// Keys and values are marshaled by encoding/json. An error is returned if one of them can't be marshaled,
+// and the lines written before that are left in w.
+// The entries are encoded and written to w with the read lock held, so no copy of them is made,
+// but writers of the cache wait for a slow w, and MarshalJSON methods of keys and values must not modify the cache.
+func (cache *LruCache[K, V]) ExportJSONL(w io.Writer) error {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	encoder := json.NewEncoder(w)
+	for element := cache.l.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*entry[K, V])
+		if err := encoder.Encode(jsonlEntry[K, V]{entry.k, entry.v, entry.size}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportJSONL reads JSON Lines from r and puts an entry for each line with PutSize, in line order,
+// so the entry of the last line is the most recently used one. Empty lines are skipped.
+// decode is called with each line to reconstruct the entry, for example by unmarshaling it into a struct
+// with fields of types K and V, so that keys and values need not round-trip through the same JSON form.
+// Reading stops at the first line which is not valid JSON or fails to decode, and an error with the line number is returned.
+// Entries put before that stay in the cache.
+func (cache *LruCache[K, V]) ImportJSONL(r io.Reader, decode func(raw json.RawMessage) (key K, value V, size uint, err error)) error {
+	reader := bufio.NewReader(r)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if !json.Valid(line) {
+				return fmt.Errorf("line %v: invalid JSON", lineNum)
+			}
+			key, value, size, decodeErr := decode(json.RawMessage(line))
+			if decodeErr != nil {
+				return fmt.Errorf("line %v: %w", lineNum, decodeErr)
+			}
+			cache.PutSize(key, value, size)
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
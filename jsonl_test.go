@@ -0,0 +1,114 @@
+package lrucache_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/mkch/lrucache"
+	"strings"
+	"testing"
+)
+
+func TestExportJSONL(t *testing.T) {
+	cache := lrucache.New[string, any](10, nil)
+	cache.Put("a", 1)
+	cache.PutSize("b", []string{"x", "y"}, 2)
+	cache.Put("c", map[string]bool{"z": true})
+	cache.Get("a")
+	var buf bytes.Buffer
+	if err := cache.ExportJSONL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"key":"b","value":["x","y"],"size":2}
+{"key":"c","value":{"z":true},"size":1}
+{"key":"a","value":1,"size":1}
+`
+	if str := buf.String(); str != expected {
+		t.Fatalf("Wrong output of LruCache.ExportJSONL. %q expected, but %q got", expected, str)
+	}
+
+	cache.Put("d", func() {})
+	if err := cache.ExportJSONL(&buf); err == nil {
+		t.Fatal("LruCache.ExportJSONL should fail for unmarshalable value")
+	}
+}
+
+// limitedWriter fails the writes after n.
+type limitedWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.n == 0 {
+		return 0, errors.New("full")
+	}
+	w.n--
+	return w.Buffer.Write(p)
+}
+
+func TestExportJSONLWriteError(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	for i := 0; i < 5; i++ {
+		cache.Put(i, i)
+	}
+	w := &limitedWriter{n: 2}
+	if err := cache.ExportJSONL(w); err == nil || err.Error() != "full" {
+		t.Fatalf("Wrong error returned by LruCache.ExportJSONL. full expected, but %v returned", err)
+	}
+	// Each entry is written as it is encoded.
+	expected := `{"key":0,"value":0,"size":1}
+{"key":1,"value":1,"size":1}
+`
+	if str := w.String(); str != expected {
+		t.Fatalf("Wrong output of LruCache.ExportJSONL. %q expected, but %q got", expected, str)
+	}
+	cache.Put(5, 5) // The read lock was released.
+}
+
+func decodeStringInt(raw json.RawMessage) (key string, value int, size uint, err error) {
+	var entry struct {
+		Key   string
+		Value int
+		Size  uint
+	}
+	if err = json.Unmarshal(raw, &entry); err != nil {
+		return
+	}
+	return entry.Key, entry.Value, entry.Size, nil
+}
+
+func TestImportJSONL(t *testing.T) {
+	cache := lrucache.New[string, int](3, nil)
+	input := `{"key":"a","value":1,"size":1}
+
+{"key":"b","value":2,"size":1}
+{"key":"c","value":3,"size":1}
+{"key":"d","value":4,"size":1}`
+	if err := cache.ImportJSONL(strings.NewReader(input), decodeStringInt); err != nil {
+		t.Fatal(err)
+	}
+	// "a" evicted, "d" is the most recently used.
+	var buf bytes.Buffer
+	cache.ExportJSONL(&buf)
+	expected := `{"key":"b","value":2,"size":1}
+{"key":"c","value":3,"size":1}
+{"key":"d","value":4,"size":1}
+`
+	if str := buf.String(); str != expected {
+		t.Fatalf("Wrong entries imported by LruCache.ImportJSONL. %q expected, but %q got", expected, str)
+	}
+
+	err := cache.ImportJSONL(strings.NewReader(`{"key":"e","value":5,"size":1}
+{"key":"f",`), decodeStringInt)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 2:") {
+		t.Fatalf("Wrong error returned by LruCache.ImportJSONL: %v", err)
+	}
+	err = cache.ImportJSONL(strings.NewReader(`{"key":1}`), decodeStringInt)
+	if err == nil || !strings.HasPrefix(err.Error(), "line 1:") {
+		t.Fatalf("Wrong error returned by LruCache.ImportJSONL: %v", err)
+	}
+	if value, _ := cache.Get("e"); value != 5 {
+		t.Fatalf("Wrong value returned by LruCache.Get. 5 expected, but %v returned", value)
+	}
+}
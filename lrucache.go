@@ -1,189 +1,1665 @@
-// Package lrucache implements a thread safe LRU(Least Recently Used) cache that holds a limited number of values.
+// Package lrucache implements a thread safe, generic LRU(Least Recently Used) cache that holds a limited number of values.
 // Each time a value is accessed, it is moved to the head of a queue. When a value is added to a full cache, the value at the end of that queue is evicted.
 package lrucache
 
 import (
 	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // EntryRemoved is the function called for entries that have been removed.
-// newValue is the new value which replaced the old one, if any.
-type EntryRemoved func(key, oldValue, newValue interface{})
+// newValue is the new value which replaced the old one, if any, or the zero value of V otherwise.
+// It is called by the goroutine which removed the entries, after the mutex of the cache has been unlocked,
+// so it may call the methods of the cache, which see the cache as already changed. The same holds for
+// EntryRemovedReason, the per-entry functions of GetEnsureWithRemoved and the channels of Events.
+// Only the functions documented as such, like the one passed to WithEvictionVeto, are called with the mutex held.
+type EntryRemoved[K comparable, V any] func(key K, oldValue, newValue V)
 
 // CreateEntry is the function computes the value and entry size for the key.
 // Called by GetEnsure to compute a cache miss
-type CreateEntry func(key interface{}) (value interface{}, size uint)
+type CreateEntry[K comparable, V any] func(key K) (value V, size uint)
 
-type entry struct {
-	k, v interface{}
-	size uint
+// Option configures optional behavior of a LruCache. See New.
+// Options whose arguments do not mention K and V need explicit type arguments, e.g. WithValuePool[string, []byte](pool).
+type Option[K comparable, V any] func(cache *LruCache[K, V])
+
+// ValuePool is where WithValuePool offers evicted values. *sync.Pool implements it.
+type ValuePool interface {
+	Put(x any)
+}
+
+// WithValuePool makes the cache offer every value evicted to make space back to pool,
+// after the EntryRemoved function, if any, has been called.
+// Values replaced by PutSize or removed by Remove are returned to the caller and never pooled.
+// The caller must not retain references to values which may be evicted, since they can be
+// reused by anyone getting from pool. Values still cached, including those returned by Get, are unaffected.
+func WithValuePool[K comparable, V any](pool ValuePool) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.valuePool = pool
+	}
+}
+
+// WithMemorySampler enables EstimatedMemory.
+// sizer measures the memory used by an entry, for example the length of its serialized form.
+// samples is the maximum number of entries measured by each EstimatedMemory call.
+func WithMemorySampler[K comparable, V any](sizer func(key K, value V) uint, samples int) Option[K, V] {
+	if samples <= 0 {
+		panic("Invalid sample count")
+	}
+	return func(cache *LruCache[K, V]) {
+		cache.memorySizer = sizer
+		cache.memorySamples = samples
+	}
+}
+
+// WithSizer makes Put and PutMulti compute the entry size of a value with sizer, instead of using 1,
+// for example the length of a []byte value. The size is computed again when a value is replaced.
+// Methods taking a size, such as PutSize, still use the size passed in.
+func WithSizer[K comparable, V any](sizer func(key K, value V) uint) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.sizer = sizer
+	}
+}
+
+// WithCallbackPanicHandler makes the cache recover from panics of the EntryRemoved, EntryRemovedReason
+// and EntryRemovedHits functions and the per-entry functions of GetEnsureWithRemoved, and call handler with the recovered values,
+// instead of propagating the panics to the callers of Put, Remove etc. The remaining callbacks are still called.
+// handler is also called with the panics of the create functions of GetEnsureAsync.
+// By default the panics are propagated, and the callbacks of the other removals of the same call are skipped.
+// Either way, the cache itself is consistent, since the callbacks are called after the change.
+func WithCallbackPanicHandler[K comparable, V any](handler func(recovered any)) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.callbackPanicHandler = handler
+	}
 }
 
-type LruCache struct {
-	m            map[interface{}]*list.Element
-	l            *list.List
-	maxSize      uint
-	size         uint
-	entryRemoved EntryRemoved
-	mutex        sync.RWMutex
+// WithPromotionThreshold makes the cache resistant to scans of keys accessed only once, as segmented LRU does.
+// A new entry is added to a probationary segment at the end of the queue, behind the protected entries,
+// and is promoted to the head of the queue, becoming protected, only after it has been found n times by Get,
+// GetEnsure or the like, or touched by Touch. Until then, finding or replacing it moves it to the head
+// of the probationary segment, which is evicted from first. The protected entries are at most three quarters
+// of maxSize: when a promotion makes them larger, the least recently used ones are demoted to the probationary
+// segment, where they are evicted unless found n times again. So a new working set fitting the probationary
+// segment replaces an old one that is no longer used. Replacing a protected entry moves it to the head of the queue.
+func WithPromotionThreshold[K comparable, V any](n uint) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.promotionThreshold = n
+	}
+}
+
+// WithEvictionVeto makes the cache consult veto before evicting an entry to make space.
+// If veto returns false, the entry is kept and the next least recently used one is considered instead.
+// If all entries are vetoed, nothing is evicted and the size of the cache temporarily exceeds maxSize
+// until a later put finds something to evict. So veto should not reject too many entries, or the cache grows unbounded.
+// veto is called with the mutex held, so it must be fast and must not access the cache.
+func WithEvictionVeto[K comparable, V any](veto func(key K, value V, size uint) bool) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.evictionVeto = veto
+	}
+}
+
+// WithExpectedEntries sizes the cache for about n entries up front, saving the cost of growing during warm-up.
+// maxSize is usually a byte budget which says little about the number of entries, so set n to the number
+// of entries expected when the cache is full.
+func WithExpectedEntries[K comparable, V any](n int) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.expectedEntries = n
+	}
+}
+
+// WithMinResidency protects entries added less than d ago from being evicted to make space,
+// giving new entries a chance to be accessed before a burst of puts pushes them out.
+// If all entries are that young, nothing is evicted and the size of the cache temporarily exceeds maxSize
+// until a later put finds an entry old enough. A long d with a small maxSize can make the cache
+// much larger than maxSize under a high put rate.
+func WithMinResidency[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.minResidency = d
+	}
+}
+
+// WithKeyValidator makes the cache check keys with validate, e.g. to reject empty keys, or nil keys when K is an interface type.
+// Each method taking a key, such as Get, Put, PutSize and Remove, calls validate with the key before anything else,
+// without holding the mutex, and panics if a non-nil error is returned.
+// Without this option no validation is done.
+func WithKeyValidator[K comparable, V any](validate func(key K) error) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.keyValidator = validate
+	}
+}
+
+type entry[K comparable, V any] struct {
+	k       K
+	v       V
+	size    uint
+	hits    uint      // Number of times found by Get.
+	created time.Time // When the entry was added.
+	prefix  string    // See WithFairEviction.
+	// See GetEnsureWithRemoved.
+	onRemoved func(newValue V)
+	// See PutWithPriority.
+	priority int
+	expires  time.Time // Zero if the entry never expires. See PutWithTTL.
+	used     time.Time // When the entry was last used, if WithMaxIdle is used.
+	accessed uint32    // Accessed atomically. See WithApproximateLRU.
+	// The element in an lfuBucket or a queue of Policy2Q, and the lfuBucket in LruCache.lfu. See WithPolicy.
+	policyElement, lfuBucket *list.Element
+	inA1in                   bool // Whether the entry is in the A1in queue of Policy2Q.
+	negative                 bool // Whether the entry is a cached "not found". See GetEnsureNeg.
+	// Whether the entry is in the probationary segment, and the times it has been found there.
+	// See WithPromotionThreshold.
+	probationary  bool
+	probationHits uint
+}
+
+// victimScanLimit is the maximum number of entries at the end of the queue examined to choose
+// the one to evict, once entries with priorities have been put or fair eviction is enabled.
+// See PutWithPriority and WithFairEviction.
+const victimScanLimit = 8
+
+// LruCache is a LRU cache of values of type V for keys of type K.
+type LruCache[K comparable, V any] struct {
+	operations atomic.Uint64 // See OperationCount.
+	stats      stats
+	m          map[K]*list.Element
+	l          *list.List
+	maxSize    uint
+	size       uint
+	// Copies of size and len(m) read by Size and Len without locking.
+	loadedSize   atomic.Uint64
+	loadedLen    atomic.Int64
+	countOnly    bool // See NewCount.
+	entryRemoved EntryRemoved[K, V]
+	// See WithEntryRemovedReason.
+	entryRemovedReason   EntryRemovedReason[K, V]
+	entryRemovedHits     EntryRemovedHits[K, V] // See WithEntryRemovedHits.
+	callbackPanicHandler func(recovered any)    // See WithCallbackPanicHandler.
+	asyncCallbacks       *asyncCallbacks[K, V]  // See WithAsyncCallbacks.
+	valuePool            ValuePool
+	sizer                func(key K, value V) uint // See WithSizer.
+	// See WithMemorySampler.
+	memorySizer        func(key K, value V) uint
+	memorySamples      int
+	promotionThreshold uint
+	evictionVeto       func(key K, value V, size uint) bool
+	autoGrow           *autoGrow
+	expectedEntries    int
+	minResidency       time.Duration
+	readFallback       *readFallback[K, V]
+	fairEviction       *fairEviction[K]
+	keyValidator       func(key K) error
+	evictionAges       EvictionAgeStats
+	missCounts         *missCounts[K]
+	// The segments of WithPromotionThreshold: the first probationary element, and the size of the protected entries.
+	probation     *list.Element
+	protectedSize uint
+	// Whether PutWithPriority has ever been called with a non-zero priority.
+	prioritized bool
+	// See WithDefaultTTL.
+	defaultTTL time.Duration
+	maxIdle    time.Duration // See WithMaxIdle.
+	// See WithApproximateLRU.
+	approximate bool
+	// See WithPolicy.
+	policy  Policy
+	lfu     *list.List  // The lfuBuckets of PolicyLFU.
+	twoQ    *twoQ[K]    // The queues of Policy2Q.
+	tinyLFU *tinyLFU[K] // See WithTinyLFU.
+	// See WithCollected.
+	collected func(value V) bool
+	// Whether entries which expire have ever been put, see PutWithTTL and WithDefaultTTL.
+	expiring bool
+	// See PutWithDeps. Both are nil until PutWithDeps is called.
+	dependents   map[K]map[K]struct{} // Keys depending on a key.
+	dependencies map[K][]K            // Keys a key depends on.
+	// Keys being created by GetEnsure and GetEnsureAsync.
+	filling map[K]*flight[V]
+	now     func() time.Time // See WithClock.
+	// See Events.
+	subscriptions subscriptions[K, V]
+	sweeper       sweeper // See StartSweeper.
+	mutex         sync.RWMutex
 }
 
 // New creates a LRU cache.
 // maxSize is the maximum size of the cache, aka the sum of entry sizes passed in PutSize and returned by CreateEntry.
 // entryRemoved is a callback function which is called every time an entry was removed.
-func New(maxSize uint, entryRemoved EntryRemoved) *LruCache {
+// options, if any, are applied in order.
+func New[K comparable, V any](maxSize uint, entryRemoved EntryRemoved[K, V], options ...Option[K, V]) *LruCache[K, V] {
 	if maxSize == 0 {
 		panic("Invalid cache size")
 	}
-	return &LruCache{m: make(map[interface{}]*list.Element), l: list.New(), maxSize: maxSize, entryRemoved: entryRemoved}
+	cache := &LruCache[K, V]{l: list.New(), maxSize: maxSize, entryRemoved: entryRemoved, now: time.Now}
+	for _, option := range options {
+		option(cache)
+	}
+	cache.m = make(map[K]*list.Element, cache.expectedEntries)
+	return cache
+}
+
+// NewCount creates a LRU cache which holds at most maxEntries entries, whatever their sizes.
+// Every entry counts as size 1: the sizes passed to PutSize and the like, returned by CreateEntry,
+// or computed by the WithSizer function are ignored, so Size equals Len and MaxSize is maxEntries.
+// Mixing sizes is not supported: a cache created by NewCount can't weigh some entries more than others,
+// and a size other than 1 is not an error but is counted as 1, so use New for entries of different sizes.
+func NewCount[K comparable, V any](maxEntries uint, entryRemoved EntryRemoved[K, V], options ...Option[K, V]) *LruCache[K, V] {
+	cache := New(maxEntries, entryRemoved, options...)
+	cache.countOnly = true
+	return cache
+}
+
+// NewWithEntries creates a LRU cache as New does, holding entries from the most recently used to the least recently used,
+// e.g. a working set taken by SnapshotFunc, without locking the cache for each of them.
+// If the entries are larger than maxSize, the least recently used ones are evicted, and the entryRemoved function
+// is called for them, before NewWithEntries returns. If a key appears more than once, its first entry is kept,
+// and the entryRemoved function is called for the others as replaced.
+func NewWithEntries[K comparable, V any](maxSize uint, entryRemoved EntryRemoved[K, V], entries []Entry[K, V], options ...Option[K, V]) *LruCache[K, V] {
+	cache := New(maxSize, entryRemoved, options...)
+	for i := range entries {
+		cache.validateKey(entries[i].Key)
+	}
+	cache.m = make(map[K]*list.Element, max(len(entries), cache.expectedEntries))
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	for i := len(entries) - 1; i >= 0; i-- {
+		_, _, placed := cache.place(entries[i].Key, entries[i].Value, entries[i].Size, 0)
+		removals = append(removals, placed...)
+	}
+	removals = append(removals, cache.trim()...)
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return cache
+}
+
+// entrySize returns the size of an entry put with size, which is 1 for a cache created by NewCount.
+func (cache *LruCache[K, V]) entrySize(size uint) uint {
+	if cache.countOnly {
+		return 1
+	}
+	return size
 }
 
 // MaxSize returns the the maximum size of the cache. See New.
-func (cache *LruCache) MaxSize() uint {
+func (cache *LruCache[K, V]) MaxSize() uint {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+
 	return cache.maxSize
 }
 
+// SetMaxSize changes the maximum size of the cache to maxSize, which must not be 0.
+// If the cache is larger than maxSize, entries are evicted as if by a put until it is not,
+// and the EntryRemoved function is called for them after the mutex has been unlocked.
+func (cache *LruCache[K, V]) SetMaxSize(maxSize uint) {
+	if maxSize == 0 {
+		panic("Invalid cache size")
+	}
+	cache.mutex.Lock()
+	cache.maxSize = maxSize
+	if cache.promotionThreshold > 0 {
+		cache.demote()
+	}
+	removals := cache.trim()
+	cache.mutex.Unlock()
+	cache.notify(removals)
+}
+
 // Size returns the current size of the cache.
-func (cache *LruCache) Size() uint {
+// It is read without locking, so it does not wait for writers, but it may see a write in progress,
+// e.g. the size of a put entry added before the entries evicted for it are removed, exceeding MaxSize for a moment.
+func (cache *LruCache[K, V]) Size() uint {
+	return uint(cache.loadedSize.Load())
+}
+
+// Len returns the number of entries in the cache, which differs from Size if entry sizes are not 1.
+// Like Size, it is read without locking.
+func (cache *LruCache[K, V]) Len() int {
+	return int(cache.loadedLen.Load())
+}
+
+// publishSize makes the size and the number of entries readable by Size and Len.
+// Must be called with the mutex locked, after each change of them.
+func (cache *LruCache[K, V]) publishSize() {
+	cache.loadedSize.Store(uint64(cache.size))
+	cache.loadedLen.Store(int64(len(cache.m)))
+}
+
+// validateKey panics if key is rejected by the WithKeyValidator function.
+func (cache *LruCache[K, V]) validateKey(key K) {
+	if cache.keyValidator == nil {
+		return
+	}
+	if err := cache.keyValidator(key); err != nil {
+		panic(fmt.Sprintf("Invalid key %#v: %v", key, err))
+	}
+}
+
+// OperationCount returns the number of operations served so far, counting each call of
+// the methods getting, putting or removing entries, such as Get, Put and Remove, once.
+// It is read without locking, and is meant to compute throughput by differencing.
+func (cache *LruCache[K, V]) OperationCount() uint64 {
+	return cache.operations.Load()
+}
+
+// EstimatedMemory returns the approximate memory used by all cached entries, or 0 if WithMemorySampler was not used.
+// It measures a few entries picked by map iteration order with the sizer passed to WithMemorySampler,
+// and multiplies their average by the number of entries. The result is a rough figure which can be far off
+// if entry memory varies a lot, and it changes from call to call even if the cache is not modified.
+// The sizer is called without holding the mutex.
+func (cache *LruCache[K, V]) EstimatedMemory() uint {
+	if cache.memorySizer == nil {
+		return 0
+	}
+	cache.mutex.RLock()
+	count := len(cache.m)
+	samples := make([]Entry[K, V], 0, cache.memorySamples)
+	for _, element := range cache.m {
+		if len(samples) == cache.memorySamples {
+			break
+		}
+		entry := element.Value.(*entry[K, V])
+		samples = append(samples, Entry[K, V]{entry.k, entry.v, entry.size})
+	}
+	cache.mutex.RUnlock()
+
+	if len(samples) == 0 {
+		return 0
+	}
+	var total uint
+	for _, sample := range samples {
+		total += cache.memorySizer(sample.Key, sample.Value)
+	}
+	return total * uint(count) / uint(len(samples))
+}
+
+// KeyMemory returns the number of bytes used by the contents of string keys, which is a lower bound of
+// the memory used by keys. Keys of other types are not counted.
+// Each distinct key is stored once no matter how many operations used it, so no interning is needed.
+// It iterates all entries with the read lock held and is O(n).
+func (cache *LruCache[K, V]) KeyMemory() (memory uint) {
 	cache.mutex.RLock()
 	defer cache.mutex.RUnlock()
+	for key := range cache.m {
+		if str, ok := any(key).(string); ok {
+			memory += uint(len(str))
+		}
+	}
+	return
+}
+
+// EvictionAgeStats is the statistics of the ages of entries evicted to make space,
+// where the age of an entry is the time since it was added.
+type EvictionAgeStats struct {
+	Count uint64        // Number of evicted entries.
+	Mean  time.Duration // Mean age.
+	Max   time.Duration // Age of the oldest entry ever evicted.
+	total time.Duration
+}
 
-	return cache.size
+// EvictionAgeStats returns the statistics of the ages of entries evicted to make space since the cache was created.
+// They tell how long entries survive, to compare with how long they are reused.
+func (cache *LruCache[K, V]) EvictionAgeStats() (stats EvictionAgeStats) {
+	cache.mutex.RLock()
+	stats = cache.evictionAges
+	cache.mutex.RUnlock()
+	if stats.Count > 0 {
+		stats.Mean = stats.total / time.Duration(stats.Count)
+	}
+	stats.total = 0
+	return
+}
+
+// AgeHistogram returns the number of entries in each age bucket, where the age of an entry is the time since it was added.
+// buckets are the upper bounds of the buckets in ascending order. counts[i] is the number of entries
+// younger than buckets[i] but not younger than buckets[i-1], and the extra counts[len(buckets)] is the number of
+// entries not younger than the last bound.
+func (cache *LruCache[K, V]) AgeHistogram(buckets []time.Duration) (counts []int) {
+	counts = make([]int, len(buckets)+1)
+	now := cache.now()
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		age := now.Sub(element.Value.(*entry[K, V]).created)
+		i := sort.Search(len(buckets), func(i int) bool { return age < buckets[i] })
+		counts[i]++
+	}
+	return
 }
 
-// Get returns the value for key or nil if no value is found.
+// Get returns the value for key and true, or the zero value and false if no value is found.
 // If a value was returned, it is moved to the head of the queue.
-func (cache *LruCache) Get(key interface{}) (value interface{}) {
+// On a miss, the sibling cache passed to WithReadFallback, if any, is consulted.
+func (cache *LruCache[K, V]) Get(key K) (value V, ok bool) {
+	if value, ok = cache.GetLocal(key); !ok && cache.readFallback != nil {
+		value, ok = cache.getFallback(key)
+	}
+	return
+}
+
+// GetLocal does the same work as Get except it never consults the sibling cache passed to WithReadFallback.
+func (cache *LruCache[K, V]) GetLocal(key K) (value V, ok bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	if cache.approximate {
+		var done bool
+		if value, ok, done = cache.getShared(key); done {
+			return
+		}
+	}
 	cache.mutex.Lock()
-	defer cache.mutex.Unlock()
-	var element *list.Element
-	if element = cache.m[key]; element != nil {
-		value = element.Value.(*entry).v
-		cache.l.MoveBefore(element, cache.l.Front())
+	element, removals := cache.lookup(key)
+	if element != nil {
+		value = element.Value.(*entry[K, V]).v
+		ok = true
+		cache.hit(element)
 	}
+	cache.lookedUp(key, ok)
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// GetNoPromote does the same work as GetLocal except the entry found is not moved in the queue,
+// nor counted as a use by the eviction policy, so the lookup does not protect it from eviction.
+// Unlike Peek, the lookup is counted as a hit or a miss, and an expired entry is removed.
+func (cache *LruCache[K, V]) GetNoPromote(key K) (value V, ok bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	if element != nil {
+		value, ok = element.Value.(*entry[K, V]).v, true
+	}
+	cache.lookedUp(key, ok)
+	cache.mutex.Unlock()
+	cache.notify(removals)
 	return
 }
 
+// Peek returns the value for key and true, or the zero value and false if no value is found, like GetLocal,
+// except the entry is not moved in the queue and the lookup is not counted as a hit or a miss.
+// Only the read lock is held, so concurrent calls of Peek do not block each other.
+// An expired entry is reported as not found but left for the next Get to remove.
+func (cache *LruCache[K, V]) Peek(key K) (value V, ok bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	if element := cache.m[key]; element != nil {
+		if entry := element.Value.(*entry[K, V]); !cache.expired(entry) {
+			value, ok = entry.v, true
+		}
+	}
+	return
+}
+
+// Contains returns whether key is in the cache, as Peek does, but without reading the value.
+// The entry is not moved in the queue. An expired entry is reported as absent.
+func (cache *LruCache[K, V]) Contains(key K) bool {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	element := cache.m[key]
+	return element != nil && !cache.expired(element.Value.(*entry[K, V]))
+}
+
+// GetOldest returns the least recently used entry without moving it in the queue.
+// ok is false if the cache is empty. Expired entries are skipped.
+func (cache *LruCache[K, V]) GetOldest() (key K, value V, ok bool) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	for element := cache.l.Back(); element != nil; element = element.Prev() {
+		if entry := element.Value.(*entry[K, V]); !cache.expired(entry) {
+			return entry.k, entry.v, true
+		}
+	}
+	return
+}
+
+// GetNewest returns the most recently used entry without moving it in the queue.
+// ok is false if the cache is empty. Expired entries are skipped.
+func (cache *LruCache[K, V]) GetNewest() (key K, value V, ok bool) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		if entry := element.Value.(*entry[K, V]); !cache.expired(entry) {
+			return entry.k, entry.v, true
+		}
+	}
+	return
+}
+
+// Touch moves the entry of key to the head of the queue without reading the value, and returns whether key is
+// in the cache. Unlike Get, it does not count as a hit or a miss, and the promotion threshold does not apply:
+// an entry in the probationary segment of WithPromotionThreshold is promoted at once.
+func (cache *LruCache[K, V]) Touch(key K) bool {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	if element != nil {
+		if cache.promotionThreshold > 0 && element.Value.(*entry[K, V]).probationary {
+			cache.promote(element)
+		} else {
+			cache.l.MoveToFront(element)
+		}
+		if cache.maxIdle > 0 {
+			element.Value.(*entry[K, V]).used = cache.now()
+		}
+		if cache.policy != PolicyLRU {
+			cache.policyUse(element)
+		}
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return element != nil
+}
+
+// ValueSize is a value and its entry size.
+type ValueSize[V any] struct {
+	Value V
+	Size  uint
+}
+
+// GetMultiWithSize returns the values and entry sizes for keys with the mutex locked once.
+// Keys not found are absent from the result. Found entries are moved to the head of the queue in the order of keys,
+// so the last found key becomes the most recently used.
+func (cache *LruCache[K, V]) GetMultiWithSize(keys []K) map[K]ValueSize[V] {
+	for _, key := range keys {
+		cache.validateKey(key)
+	}
+	cache.operations.Add(1)
+	result := make(map[K]ValueSize[V])
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	defer func() {
+		cache.mutex.Unlock()
+		cache.notify(removals)
+	}()
+	for _, key := range keys {
+		element, expired := cache.lookup(key)
+		removals = append(removals, expired...)
+		if element != nil {
+			entry := element.Value.(*entry[K, V])
+			result[key] = ValueSize[V]{entry.v, entry.size}
+			cache.hit(element)
+		}
+		cache.lookedUp(key, element != nil)
+	}
+	return result
+}
+
+// lookedUp records a lookup of key. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) lookedUp(key K, hit bool) {
+	if hit {
+		cache.stats.hits.Add(1)
+	} else {
+		cache.stats.misses.Add(1)
+	}
+	if cache.autoGrow != nil {
+		cache.growOnMisses(hit)
+	}
+	if cache.tinyLFU != nil {
+		cache.tinyLFU.record(key)
+	}
+	if !hit && cache.missCounts != nil {
+		cache.missCounts.missed(key)
+	}
+}
+
+// hit records a hit of element and moves it to the head of the queue, or of the probationary segment
+// if the promotion threshold is not reached, see WithPromotionThreshold.
+func (cache *LruCache[K, V]) hit(element *list.Element) {
+	entry := element.Value.(*entry[K, V])
+	entry.hits++
+	if cache.maxIdle > 0 {
+		entry.used = cache.now()
+	}
+	if cache.promotionThreshold > 0 {
+		cache.segmentUse(element, true)
+	} else {
+		cache.l.MoveToFront(element)
+	}
+	if cache.policy != PolicyLRU {
+		cache.policyUse(element)
+	}
+}
+
 // GetEnsure does similar work as Get except it creates the value, and moves it to the head of the queue, if not found.
-func (cache *LruCache) GetEnsure(key interface{}, create CreateEntry) (value interface{}) {
-	if value = cache.Get(key); value != nil {
+// create is called without holding the mutex. Concurrent misses of the same key are coalesced: create is called
+// once and all the callers return its value, including callers of GetEnsureErr, GetEnsureCtx and GetEnsureAsync
+// in the meantime.
+// If a value for key is put while create is running, that value is kept and returned, and the created one
+// is discarded and passed to the EntryRemoved function as oldValue.
+// If create panics, the panic is propagated to its caller and the waiting callers call create themselves.
+// See GetEnsureParallel to call create concurrently instead.
+func (cache *LruCache[K, V]) GetEnsure(key K, create CreateEntry[K, V]) (value V) {
+	value, _ = cache.getEnsure(key, withoutErr(create), false)
+	return
+}
+
+// GetEnsureErr does the same work as GetEnsure except create can fail. If create returns an error,
+// nothing is cached, and the error is returned to its caller and to the callers of GetEnsureErr waiting for it.
+// The callers of GetEnsure waiting for it call create themselves, as if create panicked.
+// err is nil if the value is found or created successfully, including when the created value is discarded
+// because a value for key was put meanwhile.
+func (cache *LruCache[K, V]) GetEnsureErr(key K, create func(key K) (value V, size uint, err error)) (value V, err error) {
+	return cache.getEnsure(key, create, true)
+}
+
+// getEnsure implements GetEnsure and GetEnsureErr. shareErr is whether an error of the create being waited for
+// is returned, rather than retried.
+func (cache *LruCache[K, V]) getEnsure(key K, create func(key K) (V, uint, error), shareErr bool) (value V, err error) {
+	var ok bool
+	if value, ok = cache.Get(key); ok {
+		return
+	}
+
+	cache.mutex.Lock()
+	element, expired := cache.lookup(key)
+	if element != nil {
+		// Cached meanwhile.
+		value = element.Value.(*entry[K, V]).v
+		cache.mutex.Unlock()
+		return
+	}
+	if flight := cache.filling[key]; flight != nil {
+		flight.joined++
+		cache.mutex.Unlock()
+		cache.notify(expired)
+		<-flight.done
+		if flight.err != nil && shareErr && flight.err != errAbandoned {
+			return value, flight.err
+		}
+		if !flight.ok {
+			return cache.getEnsure(key, create, shareErr)
+		}
+		return flight.value, nil
+	}
+	flight := cache.startFill(key)
+	cache.mutex.Unlock()
+	cache.notify(expired)
+	return cache.fill(key, create, flight)
+}
+
+// GetEnsureParallel does similar work as GetEnsure except concurrent misses of the same key are not coalesced:
+// each caller calls create without waiting for the others, and the first value created is cached and returned
+// to all of them. The other created values are discarded and passed to the EntryRemoved function as oldValue.
+// This lowers the latency of a miss when create is CPU-bound and safe to run concurrently, at the cost of
+// the duplicated work, while GetEnsure protects a backend from a stampede of the same load.
+func (cache *LruCache[K, V]) GetEnsureParallel(key K, create CreateEntry[K, V]) (value V) {
+	return cache.GetEnsureWithRemoved(key, create, nil)
+}
+
+// GetEnsureWithRemoved does similar work as GetEnsure except onRemoved is attached to the entry created on a miss.
+// onRemoved is called after the EntryRemoved function, with the same newValue, when the created value leaves the cache:
+// replaced (newValue is the new value), evicted or removed (newValue is the zero value). It is also called with the
+// zero value if the created value is discarded because another goroutine cached a value for key first.
+// It is not attached to a value found by Get.
+func (cache *LruCache[K, V]) GetEnsureWithRemoved(key K, create CreateEntry[K, V], onRemoved func(newValue V)) (value V) {
+	var ok bool
+	if value, ok = cache.Get(key); ok {
 		return
 	}
 
 	var size uint
-	// This may take a long time, and the map may be different when create() returns
 	value, size = create(key)
 
+	var removals []removal[K, V]
 	cache.mutex.Lock()
-	if winner, ok := cache.m[key]; ok {
-		// This goroutine failed in the race. Discard.
-		cache.mutex.Unlock()
-		value = winner
-		if cache.entryRemoved != nil {
-			cache.entryRemoved(key, value, nil)
-		}
+	element, expired := cache.lookup(key)
+	if element != nil {
+		// Lost the race. Discard.
+		removals = []removal[K, V]{{key: key, oldValue: value, onRemoved: onRemoved, reason: ReasonDiscarded}}
+		value = element.Value.(*entry[K, V]).v
 	} else {
-		var oldValue interface{}
-		var evicted []*entry
-		if winner, ok := cache.m[key]; ok {
-			value = winner
-			if cache.entryRemoved != nil {
-				cache.entryRemoved(key, value, nil)
-			}
+		_, _, removals = cache.putSize(key, value, size, 0)
+		if element := cache.m[key]; element != nil {
+			element.Value.(*entry[K, V]).onRemoved = onRemoved
 		} else {
-			oldValue, evicted = cache.putSize(key, value, size)
+			// Evicted at once.
+			for i := range removals {
+				if removals[i].key == key {
+					removals[i].onRemoved = onRemoved
+				}
+			}
 		}
-		cache.mutex.Unlock()
+	}
+	cache.mutex.Unlock()
+	cache.notify(append(expired, removals...))
+	return
+}
 
-		if cache.entryRemoved != nil {
-			if oldValue != nil {
-				cache.entryRemoved(key, oldValue, value)
-			}
-			for _, toEvict := range evicted {
-				cache.entryRemoved(toEvict.k, toEvict.v, nil)
-			}
+// GetEnsureAsync does similar work as GetEnsure except it does not wait for create on a cache miss.
+// If the value for key is found, it is moved to the head of the queue and returned with ready being true.
+// Otherwise the zero value and false are returned, and create is called in a new goroutine to cache the value for later calls.
+// Concurrent calls for the same key share a single pending create.
+// If create panics, the panic is recovered, so it does not crash the program, and passed to the handler of
+// WithCallbackPanicHandler, if any. Nothing is cached, and the callers of GetEnsure waiting for it call create themselves.
+func (cache *LruCache[K, V]) GetEnsureAsync(key K, create CreateEntry[K, V]) (value V, ready bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	cache.lookedUp(key, element != nil)
+	if element != nil {
+		cache.hit(element)
+		value, ready = element.Value.(*entry[K, V]).v, true
+	} else if _, filling := cache.filling[key]; !filling {
+		flight := cache.startFill(key)
+		go func() {
+			defer func() {
+				if recovered := recover(); recovered != nil && cache.callbackPanicHandler != nil {
+					cache.callbackPanicHandler(recovered)
+				}
+			}()
+			cache.fill(key, withoutErr(create), flight)
+		}()
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// flight is a pending create of GetEnsure, GetEnsureErr, GetEnsureCtx or GetEnsureAsync.
+type flight[V any] struct {
+	done   chan struct{} // Closed when the create is over.
+	value  V
+	ok     bool  // Whether value is valid. False if create panicked or failed.
+	err    error // The error returned by create, if any.
+	joined int   // The number of callers which waited for the create rather than starting it.
+
+	// For GetEnsureCtx only.
+	cancel  context.CancelFunc // Cancels the context of create.
+	waiters int                // The number of GetEnsureCtx callers waiting.
+}
+
+// startFill marks key as being created. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) startFill(key K) *flight[V] {
+	if cache.filling == nil {
+		cache.filling = make(map[K]*flight[V])
+	}
+	flight := &flight[V]{done: make(chan struct{})}
+	cache.filling[key] = flight
+	return flight
+}
+
+// withoutErr adapts create to the create function of fill.
+func withoutErr[K comparable, V any](create CreateEntry[K, V]) func(key K) (V, uint, error) {
+	return func(key K) (value V, size uint, err error) {
+		value, size = create(key)
+		return
+	}
+}
+
+// fill caches the value created by create for key, unless a value has been put meanwhile, in which case
+// that value is returned and the created one is discarded. The result is passed to the callers waiting for flight.
+// If create returns an error, nothing is cached and the error is returned.
+func (cache *LruCache[K, V]) fill(key K, create func(key K) (V, uint, error), flight *flight[V]) (value V, err error) {
+	defer func() {
+		if !flight.ok {
+			// create panicked or failed.
+			cache.mutex.Lock()
+			delete(cache.filling, key)
+			cache.mutex.Unlock()
+			close(flight.done)
 		}
+	}()
+	value, size, err := create(key)
+	if err != nil {
+		var zero V
+		flight.err = err
+		return zero, err
 	}
+
+	cache.mutex.Lock()
+	delete(cache.filling, key)
+	element, removals := cache.lookup(key)
+	if element != nil {
+		// Lost the race to a Put. Discard.
+		removals = append(removals, removal[K, V]{key: key, oldValue: value, reason: ReasonDiscarded})
+		value = element.Value.(*entry[K, V]).v
+	} else {
+		_, _, putRemovals := cache.putSize(key, value, size, 0)
+		removals = append(removals, putRemovals...)
+	}
+	flight.value, flight.ok = value, true
+	cache.mutex.Unlock()
+	close(flight.done)
+
+	cache.notify(removals)
+	return
+}
+
+// putSize puts value for key with size and priority. replaced is whether oldValue was replaced,
+// in which case the replacement is the first of removals.
+func (cache *LruCache[K, V]) putSize(key K, value V, size uint, priority int) (oldValue V, replaced bool, removals []removal[K, V]) {
+	oldValue, replaced, removals = cache.place(key, value, size, priority)
+	removals = append(removals, cache.trim()...)
 	return
 }
 
-func (cache *LruCache) putSize(key, value interface{}, size uint) (oldValue interface{}, evicted []*entry) {
-	if value == nil {
-		panic("nil value")
+// place does the same work as putSize except it does not trim the cache to maxSize.
+func (cache *LruCache[K, V]) place(key K, value V, size uint, priority int) (oldValue V, replaced bool, removals []removal[K, V]) {
+	size = cache.entrySize(size)
+	// The entries evicted by makeRoom follow the replacement, if any, which is the first of removals. See putSize.
+	fits, evicted := cache.makeRoom(key, size)
+	if !fits {
+		element := cache.m[key]
+		if element != nil {
+			oldValue, replaced = element.Value.(*entry[K, V]).v, true
+		}
+		removals = append(cache.dropOversized(element, key, value), evicted...)
+		return
 	}
+	removals = evicted
 	if element, exists := cache.m[key]; exists {
 		// Relpace the old value of existing entry.
-		entry := element.Value.(*entry)
+		entry := element.Value.(*entry[K, V])
 		oldValue = entry.v
+		replaced = true
+		cache.stats.replacements.Add(1)
+		entry.expires = cache.expiry(cache.defaultTTL)
+		if cache.maxIdle > 0 {
+			entry.used = cache.now()
+		}
 		entry.v = value
+		entry.negative = false
 		oldSize := entry.size
 		entry.size = size
+		entry.priority = priority
+		removals = append([]removal[K, V]{{key: key, oldValue: oldValue, newValue: value, onRemoved: entry.onRemoved, reason: ReasonReplaced, hits: entry.hits}}, removals...)
+		entry.onRemoved = nil
 		cache.size -= oldSize
 		cache.size += size
+		if cache.fairEviction != nil {
+			cache.fairEviction.sizes[entry.prefix] += size - oldSize
+		}
+		if entry.inA1in {
+			cache.twoQ.inSize += size - oldSize
+		}
 		// Move the element
-		cache.l.MoveBefore(element, cache.l.Front())
+		if cache.promotionThreshold > 0 {
+			if !entry.probationary {
+				cache.protectedSize += size - oldSize
+			}
+			cache.segmentUse(element, false)
+			cache.demote()
+		} else {
+			cache.l.MoveToFront(element)
+		}
+		if cache.policy != PolicyLRU {
+			cache.policyUse(element)
+		}
+		removals = append(removals, cache.removeDependents(key)...)
 	} else {
+		if cache.tinyLFU != nil && !cache.admit(key, size) {
+			removals = append(removals, removal[K, V]{key: key, oldValue: value, reason: ReasonDiscarded})
+			return
+		}
 		// Add a new entry.
-		newEntry := &entry{k: key, v: value, size: size}
+		newEntry := &entry[K, V]{k: key, v: value, size: size, priority: priority, created: cache.now(), expires: cache.expiry(cache.defaultTTL)}
+		newEntry.used = newEntry.created
+		if cache.fairEviction != nil {
+			newEntry.prefix = cache.fairEviction.prefixOf(key)
+			cache.fairEviction.sizes[newEntry.prefix] += size
+		}
+		if cache.promotionThreshold > 0 || cache.policy != PolicyLRU {
+			// Make space before adding to the probationary segment, or to a place of the policy
+			// which may be the first to evict, or the new entry would be evicted at once.
+			for cache.size+size > cache.maxSize {
+				victim := cache.victim()
+				if victim == nil {
+					break
+				}
+				removals = append(removals, cache.evictVictim(victim)...)
+			}
+		}
 		cache.size += size
-		cache.m[key] = cache.l.PushFront(newEntry)
-		// Trim
-		for cache.size > cache.maxSize {
-			eledst := cache.l.Back()
-			cache.l.Remove(eledst)
-			toEvict := eledst.Value.(*entry)
-			delete(cache.m, toEvict.k)
-			cache.size -= toEvict.size
-			evicted = append(evicted, &entry{k: toEvict.k, v: toEvict.v, size: toEvict.size})
+		if cache.promotionThreshold > 0 {
+			cache.m[key] = cache.pushProbationary(newEntry)
+		} else {
+			cache.m[key] = cache.l.PushFront(newEntry)
+		}
+		if cache.policy != PolicyLRU {
+			cache.policyAdd(cache.m[key])
+		}
+	}
+	cache.publishSize()
+	return
+}
+
+// trim evicts entries from (near) the end of the queue until the size of cache does not exceed maxSize,
+// or all remaining entries are vetoed.
+func (cache *LruCache[K, V]) trim() []removal[K, V] {
+	return cache.trimTo(cache.maxSize)
+}
+
+// trimTo is the same as trim except it trims to target instead of maxSize.
+func (cache *LruCache[K, V]) trimTo(target uint) (removals []removal[K, V]) {
+	for cache.size > target {
+		if cache.approximate {
+			cache.giveSecondChances()
+		}
+		victim := cache.victim()
+		if victim == nil {
+			break
+		}
+		removals = append(removals, cache.evictVictim(victim)...)
+	}
+	return
+}
+
+// evictVictim evicts victim to make space. An expired victim is removed as expired rather than evicted.
+func (cache *LruCache[K, V]) evictVictim(victim *list.Element) []removal[K, V] {
+	if entry := victim.Value.(*entry[K, V]); cache.expired(entry) {
+		return cache.evict(victim, cache.expiredReason(entry))
+	}
+	return cache.evict(victim, ReasonEvicted)
+}
+
+// victim returns the element to evict next, or nil if all entries are vetoed by the WithEvictionVeto function
+// or too young, see WithMinResidency.
+// It is the last element of the queue not vetoed, or, if entries with priorities or expiry have been put or fair eviction
+// is enabled, the last expired one, or else the last one with a prefix over its share, or else with the lowest priority,
+// among the last victimScanLimit elements not vetoed. Expired elements are never vetoed.
+func (cache *LruCache[K, V]) victim() *list.Element {
+	return cache.victimExcept(nil)
+}
+
+// victimExcept is the same as victim except it treats the elements in except as if they were not in the queue.
+func (cache *LruCache[K, V]) victimExcept(except map[*list.Element]bool) *list.Element {
+	return cache.victimFrom(cache.evictionFirst(), cache.evictionNext, except)
+}
+
+// victimFrom is the same as victimExcept except the elements are visited from first in the order of next
+// instead of the eviction order.
+func (cache *LruCache[K, V]) victimFrom(first *list.Element, next func(element *list.Element) *list.Element, except map[*list.Element]bool) (victim *list.Element) {
+	var now time.Time
+	if cache.minResidency > 0 {
+		now = cache.now()
+	}
+	scanned := 0
+	for element := first; element != nil && scanned < victimScanLimit; element = next(element) {
+		if except[element] {
+			continue
+		}
+		candidate := element.Value.(*entry[K, V])
+		if cache.expiring && cache.expired(candidate) {
+			return element
+		}
+		if cache.minResidency > 0 && now.Sub(candidate.created) < cache.minResidency {
+			continue
+		}
+		if cache.evictionVeto != nil && !cache.evictionVeto(candidate.k, candidate.v, candidate.size) {
+			continue
+		}
+		if cache.fairEviction != nil && cache.fairEviction.overShare(candidate.prefix, cache.maxSize) {
+			return element
+		}
+		if victim == nil || (cache.prioritized && candidate.priority < victim.Value.(*entry[K, V]).priority) {
+			victim = element
+		}
+		if !cache.prioritized && cache.fairEviction == nil && !cache.expiring {
+			break
 		}
+		scanned++
 	}
 	return
 }
 
+// EvictionPreview returns what putting an entry of size for key would evict, without changing the cache:
+// the number of evicted entries, the sum of their sizes and their keys in eviction order.
+// If key exists, its old entry is replaced rather than counted as an addition. If the new entry itself would be evicted,
+// because it does not fit or the other entries are vetoed or too young, key is among the victims,
+// and the size of its old entry, if any, is counted as freed.
+// The new entry is placed where the policy and the segments of WithPromotionThreshold would place it, and is passed
+// to the WithEvictionVeto function with the value of the old entry, or the zero value, as the value to put is not known.
+// Entries removed because they depend on the victims or on key are not included, see PutWithDeps, nor is the space
+// they free taken into account. WithFairEviction shares and WithTinyLFU admission are those before the put.
+// The result is a snapshot taken with the read lock held. A real put may evict differently if the cache changes in between.
+func (cache *LruCache[K, V]) EvictionPreview(key K, size uint) (count int, freedBytes uint, victims []K) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+
+	size = cache.entrySize(size)
+	// The size of the cache without the entry of key, compared so as not to overflow.
+	rest := cache.size
+	fits := func() bool { return size <= cache.maxSize && rest <= cache.maxSize-size }
+	var oldSize uint
+	except := make(map[*list.Element]bool)
+	// The new entry, outside of the queue.
+	placed := &list.Element{Value: &entry[K, V]{k: key, size: size, created: cache.now()}}
+	// New entries of these caches are added once the other entries have been evicted to make space. See place.
+	beforeOthers := cache.promotionThreshold > 0 || cache.policy != PolicyLRU
+	replaced := cache.m[key]
+	if replaced != nil {
+		old := replaced.Value.(*entry[K, V])
+		oldSize = old.size
+		rest -= oldSize
+		except[replaced] = true
+		placed.Value = &entry[K, V]{k: key, v: old.v, size: size, created: old.created, prefix: old.prefix}
+		beforeOthers = false
+	} else if cache.fairEviction != nil {
+		placed.Value.(*entry[K, V]).prefix = cache.fairEviction.prefixOf(key)
+	}
+	// The sum of entry sizes in A1in of Policy2Q, which decides the order of eviction.
+	inSize := cache.twoQInSize()
+	if replaced != nil && replaced.Value.(*entry[K, V]).inA1in {
+		inSize += size - oldSize
+	}
+	for !fits() {
+		var victim *list.Element
+		if beforeOthers {
+			if victim = cache.victimFrom(cache.evictionFirstIn(inSize), func(element *list.Element) *list.Element {
+				return cache.evictionNextIn(element, inSize)
+			}, except); victim == nil {
+				beforeOthers = false
+				continue
+			}
+		} else {
+			// The order of eviction with placed visited before boundary, or last if boundary is nil.
+			var boundary *list.Element
+			if replaced != nil {
+				boundary = cache.replacedBoundary(replaced, inSize)
+			}
+			first := cache.evictionFirstIn(inSize)
+			if first == boundary {
+				first = placed
+			}
+			if victim = cache.victimFrom(first, func(element *list.Element) *list.Element {
+				if element == placed {
+					return boundary
+				}
+				if next := cache.evictionNextIn(element, inSize); next != boundary {
+					return next
+				}
+				return placed
+			}, except); victim == nil {
+				break
+			}
+		}
+		except[victim] = true
+		if victim == placed {
+			freedBytes += oldSize
+			victims = append(victims, key)
+			size = 0 // Left the cache.
+			continue
+		}
+		entry := victim.Value.(*entry[K, V])
+		rest -= entry.size
+		if entry.inA1in {
+			inSize -= entry.size
+		}
+		freedBytes += entry.size
+		victims = append(victims, entry.k)
+	}
+	count = len(victims)
+	return
+}
+
+// replacedBoundary returns the element before which the replaced element is placed in eviction order,
+// or nil if it is placed last, as decided by the policy with inSize, see evictionFirstIn, for EvictionPreview.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) replacedBoundary(element *list.Element, inSize uint) *list.Element {
+	entry := element.Value.(*entry[K, V])
+	switch cache.policy {
+	case PolicyLFU:
+		// Used once more: the most recently used of the next frequency.
+		bucket := entry.lfuBucket.Next()
+		if bucket != nil && bucket.Value.(*lfuBucket).freq == entry.lfuBucket.Value.(*lfuBucket).freq+1 {
+			bucket = bucket.Next()
+		}
+		if bucket != nil {
+			return bucket.Value.(*lfuBucket).elements.Back().Value.(*list.Element)
+		}
+		return nil
+	case Policy2Q:
+		if entry.inA1in {
+			// Not reordered.
+			return cache.evictionNextIn(element, inSize)
+		}
+		// The most recently used of Am.
+		if first, _ := cache.twoQOrder(inSize); first == cache.twoQ.am {
+			if back := cache.twoQ.a1in.Back(); back != nil {
+				return back.Value.(*list.Element)
+			}
+		}
+		return nil
+	}
+	if cache.promotionThreshold > 0 && entry.probationary {
+		// The head of the probationary segment, before the protected entries.
+		return cache.probation.Prev()
+	}
+	return nil
+}
+
+// evict removes the entry of eledst for reason, and the entries depending on it.
+func (cache *LruCache[K, V]) evict(eledst *list.Element, reason Reason) []removal[K, V] {
+	if cache.promotionThreshold > 0 {
+		cache.leaveSegment(eledst)
+	}
+	cache.l.Remove(eledst)
+	if cache.policy != PolicyLRU {
+		cache.policyRemove(eledst, reason)
+	}
+	toEvict := eledst.Value.(*entry[K, V])
+	delete(cache.m, toEvict.k)
+	if toEvict.size > cache.size {
+		panic(fmt.Sprintf("Size underflow: evicting an entry of size %v from a cache of size %v", toEvict.size, cache.size))
+	}
+	cache.size -= toEvict.size
+	cache.publishSize()
+	if cache.fairEviction != nil {
+		cache.fairEviction.removed(toEvict.prefix, toEvict.size)
+	}
+	if reason == ReasonEvicted {
+		cache.stats.evictions.Add(1)
+		age := cache.now().Sub(toEvict.created)
+		cache.evictionAges.Count++
+		cache.evictionAges.total += age
+		if age > cache.evictionAges.Max {
+			cache.evictionAges.Max = age
+		}
+	}
+	return append([]removal[K, V]{{key: toEvict.k, oldValue: toEvict.v, onRemoved: toEvict.onRemoved, reason: reason, hits: toEvict.hits}},
+		cache.removeDependents(toEvict.k)...)
+}
+
 // PutSize caches value for key and moves this entry to the head of the queue. size is the entry size.
-// The return value oldValue, if not nil, is the old value replaced by value(no new entry was added).
+// If replaced is true, oldValue is the old value replaced by value(no new entry was added).
+// value can be nil or the zero value, e.g. to cache a negative lookup result. Get tells such a value from a miss by ok.
 // The non-nil EntryRemoved function passed in New() is called when an old value was replaced
 // or the last entry in the queue was evicted to make space.
-func (cache *LruCache) PutSize(key, value interface{}, size uint) (oldValue interface{}) {
-	var evicted []*entry
+// If the size of the cache would overflow uint, entries are evicted before value is added instead of after.
+// If it still would, because the entries left can not be evicted, e.g. due to WithEvictionVeto, value is discarded
+// as if evicted at once for being larger than the cache, and the old entry of key, if any, is removed.
+func (cache *LruCache[K, V]) PutSize(key K, value V, size uint) (oldValue V, replaced bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	oldValue, replaced, removals = cache.putSize(key, value, size, 0)
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// TryPutSize does the same work as PutSize if size does not exceed maxSize, and returns ok being true.
+// Otherwise nothing is cached, the entries already in the cache are left intact, and ok is false.
+// PutSize caches an entry larger than maxSize alone, after evicting all the others.
+func (cache *LruCache[K, V]) TryPutSize(key K, value V, size uint) (oldValue V, replaced, ok bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	var removals []removal[K, V]
 	cache.mutex.Lock()
-	oldValue, evicted = cache.putSize(key, value, size)
+	if cache.entrySize(size) <= cache.maxSize {
+		oldValue, replaced, removals = cache.putSize(key, value, size, 0)
+		ok = true
+	}
 	cache.mutex.Unlock()
-	if cache.entryRemoved != nil {
-		if oldValue != nil {
-			cache.entryRemoved(key, oldValue, value)
+	cache.notify(removals)
+	return
+}
+
+// PutIfAbsent does the same work as PutSize if key is not in the cache, and returns value and false.
+// Otherwise it returns the cached value and true, leaving the entry as is, not moved in the queue.
+// The check and the put are done with the mutex locked once, so of concurrent calls with the same key
+// only the first one puts its value.
+func (cache *LruCache[K, V]) PutIfAbsent(key K, value V, size uint) (actual V, loaded bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	if element != nil {
+		actual, loaded = element.Value.(*entry[K, V]).v, true
+	} else {
+		_, _, put := cache.putSize(key, value, size, 0)
+		removals = append(removals, put...)
+		actual = value
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// GetOrPut does the same work as Get if key is in the cache, moving the entry to the head of the queue,
+// and returns the cached value and true. Otherwise it does the same work as PutSize, and returns value and false.
+// Unlike PutIfAbsent, it counts as a lookup: a hit or a miss, and a use of the entry found.
+// The lookup and the put are done with the mutex locked once, so of concurrent calls with the same key
+// only the first one puts its value.
+func (cache *LruCache[K, V]) GetOrPut(key K, value V, size uint) (actual V, loaded bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	if element != nil {
+		actual, loaded = element.Value.(*entry[K, V]).v, true
+		cache.hit(element)
+	}
+	cache.lookedUp(key, loaded)
+	if !loaded {
+		_, _, put := cache.putSize(key, value, size, 0)
+		removals = append(removals, put...)
+		actual = value
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// UpdateSize changes the entry size of key to size, and returns false if key is not in the cache.
+// The entry is not moved in the queue. If the cache becomes larger than maxSize, entries are evicted as if by a put,
+// which may be the entry of key itself if it is the least recently used, and the EntryRemoved function is called
+// for them after the mutex has been unlocked.
+func (cache *LruCache[K, V]) UpdateSize(key K, size uint) bool {
+	cache.validateKey(key)
+	size = cache.entrySize(size)
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	if element != nil {
+		fits, evicted := cache.makeRoom(key, size)
+		removals = append(removals, evicted...)
+		if element = cache.m[key]; element != nil && !fits {
+			// Would be evicted at once if it could be accounted.
+			removals = append(removals, cache.evict(element, ReasonEvicted)...)
+		} else if element != nil {
+			cache.resize(element.Value.(*entry[K, V]), size)
+			removals = append(removals, cache.trim()...)
+		}
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return element != nil
+}
+
+// Replace replaces the value and the entry size of key, and returns the old value and true,
+// or does nothing and returns false if key is not in the cache.
+// Unlike PutSize, the entry is not moved in the queue and is not used as WithMaxIdle means,
+// so the order of eviction is still decided by the gets. The expiry and priority of the entry are kept.
+// The EntryRemoved function is called for the old value as if replaced by PutSize,
+// and, if the cache becomes larger than maxSize, for the entries evicted, after the mutex has been unlocked.
+// A size which would overflow the size of the cache is handled as by PutSize.
+func (cache *LruCache[K, V]) Replace(key K, value V, size uint) (oldValue V, ok bool) {
+	cache.validateKey(key)
+	size = cache.entrySize(size)
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	var fits bool
+	if element != nil {
+		oldValue, ok = element.Value.(*entry[K, V]).v, true
+		var evicted []removal[K, V]
+		fits, evicted = cache.makeRoom(key, size)
+		removals = append(removals, evicted...)
+		if element = cache.m[key]; element == nil {
+			// Removed as depending on an evicted entry.
+			var zero V
+			oldValue, ok = zero, false
+		}
+	}
+	if element != nil && !fits {
+		removals = append(removals, cache.dropOversized(element, key, value)...)
+	} else if element != nil {
+		entry := element.Value.(*entry[K, V])
+		oldValue, ok = entry.v, true
+		cache.stats.replacements.Add(1)
+		removals = append(removals, removal[K, V]{key: key, oldValue: oldValue, newValue: value, onRemoved: entry.onRemoved, reason: ReasonReplaced, hits: entry.hits})
+		entry.onRemoved = nil
+		entry.v = value
+		entry.negative = false
+		cache.resize(entry, size)
+		removals = append(removals, cache.removeDependents(key)...)
+		removals = append(removals, cache.trim()...)
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// makeRoom evicts the entries other than the one of key, as trim would after the put, until the size of the cache
+// would not overflow uint if the entry of key, existing or not, had size. fits is false if it still would,
+// because the entries left can not be evicted. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) makeRoom(key K, size uint) (fits bool, removals []removal[K, V]) {
+	for {
+		rest := cache.size
+		element := cache.m[key]
+		if element != nil {
+			rest -= element.Value.(*entry[K, V]).size
+		}
+		if size <= math.MaxUint-rest {
+			return true, removals
+		}
+		victim := cache.victimExcept(map[*list.Element]bool{element: true})
+		if victim == nil {
+			return false, removals
+		}
+		removals = append(removals, cache.evictVictim(victim)...)
+	}
+}
+
+// dropOversized handles a put of value for key whose size overflows the size of the cache, see makeRoom.
+// Such an entry would be larger than the cache, and evicted at once, if it could be accounted,
+// so value is discarded, and the old entry of element, if not nil, is removed as replaced by it.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) dropOversized(element *list.Element, key K, value V) (removals []removal[K, V]) {
+	if element != nil {
+		cache.stats.replacements.Add(1)
+		removals = cache.evict(element, ReasonReplaced)
+		removals[0].newValue = value
+	}
+	return append(removals, removal[K, V]{key: key, oldValue: value, reason: ReasonDiscarded})
+}
+
+// resize changes the size of entry in the cache to size. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) resize(entry *entry[K, V], size uint) {
+	cache.size = cache.size - entry.size + size
+	if cache.fairEviction != nil {
+		cache.fairEviction.sizes[entry.prefix] += size - entry.size
+	}
+	if entry.inA1in {
+		cache.twoQ.inSize += size - entry.size
+	}
+	if cache.promotionThreshold > 0 && !entry.probationary {
+		cache.protectedSize += size - entry.size
+	}
+	entry.size = size
+	if cache.promotionThreshold > 0 {
+		cache.demote()
+	}
+	cache.publishSize()
+}
+
+// PutWithPriority does similar work as PutSize except the entry is stored with priority.
+// PutSize and other methods store entries with priority 0.
+// Eviction is not strictly LRU once a non-zero priority has been put: the entry to evict is the least
+// recently used one with the lowest priority among the last 8 entries of the queue. So a high priority entry
+// survives longer, but is still evicted if it stays cold long enough. This scan adds a small constant cost to each eviction.
+func (cache *LruCache[K, V]) PutWithPriority(key K, value V, size uint, priority int) (oldValue V, replaced bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	if priority != 0 {
+		cache.prioritized = true
+	}
+	oldValue, replaced, removals = cache.putSize(key, value, size, priority)
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// removal is a pending call of the EntryRemoved function for a value which left the cache.
+type removal[K comparable, V any] struct {
+	key                K
+	oldValue, newValue V
+	onRemoved          func(newValue V) // See GetEnsureWithRemoved.
+	reason             Reason
+	hits               uint // See WithEntryRemovedHits.
+}
+
+// notify calls the EntryRemoved, EntryRemovedReason and EntryRemovedHits functions, and the per-entry function passed to
+// GetEnsureWithRemoved, for removals in order, sends them to the channels returned by Events,
+// and offers the evicted values to the value pool. With WithAsyncCallbacks, this is done by the worker.
+// Must be called without holding the mutex.
+func (cache *LruCache[K, V]) notify(removals []removal[K, V]) {
+	if len(removals) == 0 {
+		return
+	}
+	if cache.asyncCallbacks != nil {
+		cache.enqueue(removals)
+		return
+	}
+	cache.deliver(removals)
+}
+
+// deliver does the work of notify.
+func (cache *LruCache[K, V]) deliver(removals []removal[K, V]) {
+	for _, removal := range removals {
+		if cache.entryRemoved != nil {
+			cache.guard(func() { cache.entryRemoved(removal.key, removal.oldValue, removal.newValue) })
+		}
+		if cache.entryRemovedReason != nil {
+			cache.guard(func() { cache.entryRemovedReason(removal.key, removal.oldValue, removal.newValue, removal.reason) })
+		}
+		if cache.entryRemovedHits != nil {
+			cache.guard(func() { cache.entryRemovedHits(removal.key, removal.oldValue, removal.newValue, uint64(removal.hits)) })
 		}
-		for _, toEvict := range evicted {
-			cache.entryRemoved(toEvict.k, toEvict.v, nil)
+		if removal.onRemoved != nil {
+			cache.guard(func() { removal.onRemoved(removal.newValue) })
+		}
+	}
+	cache.publish(removals)
+	if cache.valuePool != nil {
+		for _, removal := range removals {
+			if removal.reason == ReasonEvicted {
+				cache.valuePool.Put(removal.oldValue)
+			}
+		}
+	}
+}
+
+// guard calls the callback f, passing a panic of it to the WithCallbackPanicHandler function, if any.
+func (cache *LruCache[K, V]) guard(f func()) {
+	if cache.callbackPanicHandler != nil {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				cache.callbackPanicHandler(recovered)
+			}
+		}()
+	}
+	f()
+}
+
+// GetAndGrow atomically reads the value for key, calls grow with it, stores the returned newValue with newSize,
+// and moves the entry to the head of the queue. The stored newValue is returned.
+// ok is false, and grow is not called, if no value is found.
+// grow is called with the mutex held, so it must be fast and must not access the cache.
+// The EntryRemoved function is not called for the value passed to grow, which is typically grown in place,
+// but is called for entries evicted to make space. Growing is not a replacement: the entries depending on key
+// (see PutWithDeps) are kept, so are the dependencies of the entry, and Stats.Replacements is not counted.
+// If grow panics, the entry is left unchanged and the panic is propagated after the mutex is unlocked.
+func (cache *LruCache[K, V]) GetAndGrow(key K, grow func(value V) (newValue V, newSize uint)) (value V, ok bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	defer func() {
+		cache.mutex.Unlock()
+		cache.notify(removals)
+	}()
+	if element != nil {
+		var size uint
+		entry := element.Value.(*entry[K, V])
+		value, size = grow(entry.v)
+		ok = true
+		// Not a replacement: the entry keeps its per-entry function, expiry, priority and dependencies.
+		entry.v = value
+		size = cache.entrySize(size)
+		fits, evicted := cache.makeRoom(key, size)
+		removals = append(removals, evicted...)
+		if element = cache.m[key]; element != nil && !fits {
+			// Would be evicted at once if it could be accounted.
+			removals = append(removals, cache.evict(element, ReasonEvicted)...)
+		} else if element != nil {
+			cache.resize(entry, size)
+			if cache.maxIdle > 0 {
+				entry.used = cache.now()
+			}
+			if cache.promotionThreshold > 0 {
+				cache.segmentUse(element, false)
+			} else {
+				cache.l.MoveToFront(element)
+			}
+			if cache.policy != PolicyLRU {
+				cache.policyUse(element)
+			}
+			removals = append(removals, cache.trim()...)
 		}
 	}
 	return
 }
 
-// Put calls PutSize(key, value, 1)
-func (cache *LruCache) Put(key, value interface{}) (oldValue interface{}) {
-	return cache.PutSize(key, value, 1)
+// Update atomically computes the entry of key from its current value. f is called with the value for key and true,
+// or the zero value and false if not found. If keep is true, newValue is stored with size, inserting it if absent,
+// and moved to the head of the queue, as by PutSize. Otherwise the entry, if any, is removed as by Remove.
+// value and ok are newValue and keep.
+// The EntryRemoved function is called after the mutex has been unlocked for the old value, replaced or removed,
+// and the entries evicted, if any.
+// f is called with the mutex held, so concurrent updates of the same key never overwrite each other,
+// but f must be fast and must not access the cache. If f panics, the entry is left unchanged
+// and the panic is propagated after the mutex is unlocked.
+func (cache *LruCache[K, V]) Update(key K, f func(oldValue V, existed bool) (newValue V, size uint, keep bool)) (value V, ok bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	defer func() {
+		cache.mutex.Unlock()
+		cache.notify(removals)
+	}()
+	var oldValue V
+	if element != nil {
+		oldValue = element.Value.(*entry[K, V]).v
+	}
+	value, size, ok := f(oldValue, element != nil)
+	if ok {
+		_, _, put := cache.putSize(key, value, size, 0)
+		removals = append(removals, put...)
+	} else if element != nil {
+		removals = append(removals, cache.evict(element, ReasonRemoved)...)
+	}
+	return
+}
+
+// Put calls PutSize(key, value, 1), or with the size computed by the WithSizer function if any.
+func (cache *LruCache[K, V]) Put(key K, value V) (oldValue V, replaced bool) {
+	return cache.PutSize(key, value, cache.sizeOf(key, value))
+}
+
+// sizeOf returns the entry size of value for Put.
+func (cache *LruCache[K, V]) sizeOf(key K, value V) uint {
+	if cache.sizer != nil {
+		return cache.sizer(key, value)
+	}
+	return 1
 }
 
-// Remove removes the entry for key. Returns the value for key if exists, or nil otherwise.
+// Remove removes the entry for key. Returns the value for key and true if exists, or the zero value and false otherwise.
 // The non-nil EntryRemoved function passed in New() is called when an entry was actually removed.
-func (cache *LruCache) Remove(key interface{}) (value interface{}) {
+func (cache *LruCache[K, V]) Remove(key K) (value V, ok bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
 	cache.mutex.Lock()
-	var element *list.Element
-	var k, v interface{}
-	if element = cache.m[key]; element != nil {
-		delete(cache.m, key)
-		entry := cache.l.Remove(element).(*entry)
-		value = entry.v
-		cache.size -= entry.size
-		k = entry.k
-		v = entry.v
+	element, removals := cache.lookup(key)
+	if element != nil {
+		removals = cache.evict(element, ReasonRemoved)
+		value = removals[0].oldValue
+		ok = true
 	}
 	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// TrimToSize evicts entries as if to make space for a put, until the size of the cache does not exceed target
+// or all remaining entries are vetoed, and returns the number of entries removed, including those depending on them
+// (see PutWithDeps). maxSize is unchanged, so the cache may grow again. The EntryRemoved function is called
+// for the removed entries after the mutex has been unlocked.
+func (cache *LruCache[K, V]) TrimToSize(target uint) int {
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	removals := cache.trimTo(target)
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return len(removals)
+}
 
-	if v != nil && cache.entryRemoved != nil {
-		cache.entryRemoved(k, v, nil)
+// PopOldest removes the least recently used entry and returns its key and value, for example to move it
+// to a slower store. ok is false if the cache is empty. The caller takes over the value, so the EntryRemoved function
+// and the other removal callbacks are not called for it, but are called for expired entries removed on the way
+// and for the entries depending on it (see PutWithDeps), after the mutex has been unlocked.
+func (cache *LruCache[K, V]) PopOldest() (key K, value V, ok bool) {
+	cache.operations.Add(1)
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	for element := cache.l.Back(); element != nil; element = cache.l.Back() {
+		if entry := element.Value.(*entry[K, V]); cache.expired(entry) {
+			removals = append(removals, cache.evict(element, cache.expiredReason(entry))...)
+			continue
+		}
+		popped := cache.evict(element, ReasonRemoved)
+		key, value, ok = popped[0].key, popped[0].oldValue, true
+		removals = append(removals, popped[1:]...)
+		break
 	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
 	return
 }
+
+// RemoveFunc removes the entries for which pred returns true, and returns the number of entries removed,
+// including those depending on them (see PutWithDeps).
+// The non-nil EntryRemoved function passed in New() is called for each removed entry after the mutex has been unlocked.
+// pred is called with the mutex held, so it must be fast and must not access the cache.
+// If pred panics, nothing is removed and the panic is propagated after the mutex is unlocked.
+func (cache *LruCache[K, V]) RemoveFunc(pred func(key K, value V) bool) int {
+	cache.operations.Add(1)
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	defer func() {
+		cache.mutex.Unlock()
+		cache.notify(removals)
+	}()
+	var matched []*list.Element
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		if entry := element.Value.(*entry[K, V]); pred(entry.k, entry.v) {
+			matched = append(matched, element)
+		}
+	}
+	for _, element := range matched {
+		// Already removed if it depends on another matched entry.
+		if cache.m[element.Value.(*entry[K, V]).k] == element {
+			removals = append(removals, cache.evict(element, ReasonRemoved)...)
+		}
+	}
+	return len(removals)
+}
+
+// Clear removes all entries. The EntryRemoved function is called for each of them with the zero newValue,
+// from the least recently used to the most recently used.
+// The entries are detached with the mutex locked in constant time, and the EntryRemoved function is called
+// after the mutex has been unlocked, so it may access the cache, which can already hold new entries.
+func (cache *LruCache[K, V]) Clear() {
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	l := cache.l
+	cache.l = list.New()
+	cache.m = make(map[K]*list.Element, cache.expectedEntries)
+	cache.size = 0
+	cache.probation, cache.protectedSize = nil, 0
+	cache.publishSize()
+	cache.dependents, cache.dependencies = nil, nil
+	if cache.policy != PolicyLRU {
+		cache.policyClear()
+	}
+	if cache.fairEviction != nil {
+		cache.fairEviction.sizes = make(map[string]uint)
+	}
+	cache.mutex.Unlock()
+
+	removals := make([]removal[K, V], 0, l.Len())
+	for element := l.Back(); element != nil; element = element.Prev() {
+		entry := element.Value.(*entry[K, V])
+		removals = append(removals, removal[K, V]{key: entry.k, oldValue: entry.v, onRemoved: entry.onRemoved, reason: ReasonCleared, hits: entry.hits})
+	}
+	cache.notify(removals)
+}
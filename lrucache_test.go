@@ -1,34 +1,93 @@
 package lrucache_test
 
 import (
+	"errors"
+	"fmt"
 	"github.com/mkch/lrucache"
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestPutGet(t *testing.T) {
-	cache := lrucache.New(10, nil)
+	cache := lrucache.New[int, string](10, nil)
 	cache.Put(1, "1")
 	cache.Put(2, "2")
 	if size := cache.Size(); size != 2 {
 		t.Fatalf("Wrong size. 2 expected, but %d returned.", size)
 	}
-	if value := cache.Get(1); value != "1" {
-		t.Fatalf("Wrong value returned by LruCache.Get. \"1\" expected, \"%v\" returned", value)
+	if value, ok := cache.Get(1); !ok || value != "1" {
+		t.Fatalf("Wrong value returned by LruCache.Get. \"1\", true expected, \"%v\", %v returned", value, ok)
 	}
-	if value := cache.Get(2); value != "2" {
-		t.Fatalf("Wrong value returned by LruCache.Get. \"2\" expected, but \"%v\" returned", value)
+	if value, ok := cache.Get(2); !ok || value != "2" {
+		t.Fatalf("Wrong value returned by LruCache.Get. \"2\", true expected, but \"%v\", %v returned", value, ok)
 	}
-	if value := cache.Get(3); value != nil {
-		t.Fatalf("Wrong value returned by LruCache.Get. nil expected, but \"%v\" returned", value)
+	if value, ok := cache.Get(3); ok || value != "" {
+		t.Fatalf("Wrong value returned by LruCache.Get. \"\", false expected, but \"%v\", %v returned", value, ok)
+	}
+}
+
+func TestPeek(t *testing.T) {
+	cache := lrucache.New[int, string](3, nil)
+	cache.Put(1, "1")
+	cache.Put(2, "2")
+	cache.Put(3, "3")
+	for i := 0; i < 3; i++ {
+		if value, ok := cache.Peek(1); !ok || value != "1" {
+			t.Fatalf("Wrong value returned by LruCache.Peek. \"1\", true expected, but \"%v\", %v returned", value, ok)
+		}
+	}
+	if value, ok := cache.Peek(4); ok {
+		t.Fatalf("Wrong value returned by LruCache.Peek. Nothing expected, but \"%v\" returned", value)
+	}
+	cache.Put(4, "4") // Evicts 1, still the least recently used.
+	if value, ok := cache.Peek(1); ok {
+		t.Fatalf("Wrong value returned by LruCache.Peek. Nothing expected, but \"%v\" returned", value)
+	}
+}
+
+func TestPutReplaced(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	if oldValue, replaced := cache.Put(1, 0); replaced || oldValue != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Put. 0, false expected, but %v, %v returned", oldValue, replaced)
+	}
+	if oldValue, replaced := cache.Put(1, 10); !replaced || oldValue != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Put. 0, true expected, but %v, %v returned", oldValue, replaced)
+	}
+	if value, ok := cache.Remove(1); !ok || value != 10 {
+		t.Fatalf("Wrong value returned by LruCache.Remove. 10, true expected, but %v, %v returned", value, ok)
+	}
+	if value, ok := cache.Remove(1); ok || value != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Remove. 0, false expected, but %v, %v returned", value, ok)
+	}
+}
+
+func TestGetMultiWithSize(t *testing.T) {
+	cache := lrucache.New[int, string](4, nil)
+	cache.Put(1, "1")
+	cache.PutSize(2, "2", 2)
+	cache.Put(3, "3")
+	result := cache.GetMultiWithSize([]int{2, 4, 1})
+	expected := map[int]lrucache.ValueSize[string]{1: {"1", 1}, 2: {"2", 2}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Wrong value returned by LruCache.GetMultiWithSize. %v expected, but %v returned", expected, result)
+	}
+	cache.Put(5, "5") // Evicts 3.
+	if value, ok := cache.Get(3); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
 	}
 }
 
 func TestGetEnsure(t *testing.T) {
-	cache := lrucache.New(10, nil)
-	cache.Put("key1", 100)
-	create := func(key interface{}) (value interface{}, size uint) {
+	cache := lrucache.New[string, string](10, nil)
+	cache.Put("key1", "100")
+	create := func(key string) (value string, size uint) {
 		switch key {
 		case "key2":
 			return "200", 1
@@ -39,16 +98,288 @@ func TestGetEnsure(t *testing.T) {
 	if value := cache.GetEnsure("key2", create); value != "200" {
 		t.Fatalf("Wrong value returned by LruCache.GetEnsure. \"200\" expected, but \"%v\" returned", value)
 	}
-	if value := cache.Get("key2"); value != "200" {
+	if value, _ := cache.Get("key2"); value != "200" {
 		t.Fatalf("Wrong value returned by LruCache.GetEnsure. \"200\" expected, but \"%v\" returned", value)
 	}
-	if value := cache.Get("key1"); value != 100 {
-		t.Fatalf("Wrong value returned by LruCache.GetEnsure. 100 expected, but %v returned", value)
+	if value, _ := cache.Get("key1"); value != "100" {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsure. \"100\" expected, but \"%v\" returned", value)
+	}
+}
+
+func TestNilValue(t *testing.T) {
+	type user struct{ name string }
+	cache := lrucache.New[int, *user](10, nil)
+	var creations int
+	create := func(key int) (value *user, size uint) {
+		creations++
+		return nil, 1 // Not found in the database.
+	}
+	for i := 0; i < 3; i++ {
+		if value := cache.GetEnsure(1, create); value != nil {
+			t.Fatalf("Wrong value returned by LruCache.GetEnsure. nil expected, but %v returned", value)
+		}
+	}
+	if creations != 1 {
+		t.Fatalf("create called %v times, 1 expected", creations)
+	}
+	if value, ok := cache.Get(1); !ok || value != nil {
+		t.Fatalf("Wrong value returned by LruCache.Get. nil, true expected, but %v, %v returned", value, ok)
+	}
+	if value, ok := cache.Get(2); ok || value != nil {
+		t.Fatalf("Wrong value returned by LruCache.Get. nil, false expected, but %v, %v returned", value, ok)
+	}
+	cache.Put(2, nil)
+	if oldValue, replaced := cache.Put(2, &user{"2"}); !replaced || oldValue != nil {
+		t.Fatalf("Wrong value returned by LruCache.Put. nil, true expected, but %v, %v returned", oldValue, replaced)
+	}
+	if value, ok := cache.Remove(1); !ok || value != nil {
+		t.Fatalf("Wrong value returned by LruCache.Remove. nil, true expected, but %v, %v returned", value, ok)
+	}
+}
+
+func TestGetEnsureRace(t *testing.T) {
+	var discarded []int
+	cache := lrucache.New(10, func(key, oldValue, newValue int) {
+		discarded = append(discarded, oldValue)
+	})
+	var creations int32
+	release := make(chan struct{})
+	create := func(key int) (value int, size uint) {
+		atomic.AddInt32(&creations, 1)
+		<-release
+		return 1, 1
+	}
+	var values [2]int
+	waitGroup := &sync.WaitGroup{}
+	for i := range values {
+		waitGroup.Add(1)
+		go func(i int) {
+			values[i] = cache.GetEnsure(1, create)
+			waitGroup.Done()
+		}(i)
+	}
+	for atomic.LoadInt32(&creations) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cache.Put(1, 100) // Wins the race against create.
+	close(release)
+	waitGroup.Wait()
+	if values[0] != 100 || values[1] != 100 {
+		t.Fatalf("Wrong values returned by LruCache.GetEnsure. 100, 100 expected, but %v, %v returned", values[0], values[1])
+	}
+	if !reflect.DeepEqual(discarded, []int{1}) {
+		t.Fatalf("Wrong oldValues passed to EntryRemoved. [1] expected, but %v got", discarded)
+	}
+}
+
+func TestGetEnsureSingleFlight(t *testing.T) {
+	const keys = 10
+	cache := lrucache.New[int, int](keys, nil)
+	var creations [keys]int32
+	create := func(key int) (value int, size uint) {
+		atomic.AddInt32(&creations[key], 1)
+		time.Sleep(time.Millisecond)
+		return key * 10, 1
+	}
+	waitGroup := &sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for key := 0; key < keys; key++ {
+				if value := cache.GetEnsure(key, create); value != key*10 {
+					t.Errorf("Wrong value returned by LruCache.GetEnsure. %v expected, but %v returned", key*10, value)
+				}
+			}
+		}()
+	}
+	waitGroup.Wait()
+	for key := range creations {
+		if n := atomic.LoadInt32(&creations[key]); n != 1 {
+			t.Fatalf("create called %v times for key %v, 1 expected", n, key)
+		}
+	}
+}
+
+func TestGetEnsurePanic(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("LruCache.GetEnsure should panic")
+			}
+		}()
+		cache.GetEnsure(1, func(key int) (int, uint) { panic("create failed") })
+	}()
+	if value := cache.GetEnsure(1, func(key int) (int, uint) { return 10, 1 }); value != 10 {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsure. 10 expected, but %v returned", value)
+	}
+}
+
+func TestGetEnsureErr(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	errCreate := errors.New("create failed")
+	if value, err := cache.GetEnsureErr(1, func(key int) (int, uint, error) { return 10, 1, errCreate }); err != errCreate || value != 0 {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsureErr. 0, %v expected, but %v, %v returned", errCreate, value, err)
+	}
+	if cache.Contains(1) {
+		t.Fatal("Nothing should be cached by a failed LruCache.GetEnsureErr")
+	}
+	for i := 0; i < 2; i++ { // Created, then found.
+		if value, err := cache.GetEnsureErr(1, func(key int) (int, uint, error) { return 10, 1, nil }); err != nil || value != 10 {
+			t.Fatalf("Wrong value returned by LruCache.GetEnsureErr. 10, <nil> expected, but %v, %v returned", value, err)
+		}
+	}
+
+	// Waiters of a failed create.
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go cache.GetEnsureErr(2, func(key int) (int, uint, error) {
+		close(started)
+		<-release
+		return 0, 0, errCreate
+	})
+	<-started
+	errResult := make(chan error)
+	go func() {
+		_, err := cache.GetEnsureErr(2, func(key int) (int, uint, error) { return 20, 1, nil })
+		errResult <- err
+	}()
+	valueResult := make(chan int)
+	go func() {
+		valueResult <- cache.GetEnsure(2, func(key int) (int, uint) { return 20, 1 })
+	}()
+	waitJoined(cache, 2, 2)
+	close(release)
+	if err := <-errResult; err != errCreate {
+		t.Fatalf("Wrong error returned by LruCache.GetEnsureErr. %v expected, but %v returned", errCreate, err)
+	}
+	if value := <-valueResult; value != 20 {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsure. 20 expected, but %v returned", value)
+	}
+}
+
+// waitJoined waits until n callers have joined the pending create for key.
+func waitJoined[K comparable, V any](cache *lrucache.LruCache[K, V], key K, n int) {
+	for lrucache.Joined(cache, key) < n {
+		runtime.Gosched()
+	}
+}
+
+func TestGetEnsureParallel(t *testing.T) {
+	const n = 4
+	var discarded atomic.Int32
+	cache := lrucache.New(10, nil, lrucache.WithEntryRemovedReason(func(key, oldValue, newValue int, reason lrucache.Reason) {
+		if reason == lrucache.ReasonDiscarded {
+			discarded.Add(1)
+		}
+	}))
+	var creating sync.WaitGroup
+	creating.Add(n)
+	create := func(key int) (int, uint) {
+		creating.Done()
+		creating.Wait() // All the creates run at the same time.
+		return key * 10, 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if value := cache.GetEnsureParallel(1, create); value != 10 {
+				t.Errorf("Wrong value returned by LruCache.GetEnsureParallel. 10 expected, but %v returned", value)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := discarded.Load(); n != 3 {
+		t.Fatalf("Wrong number of discarded values. 3 expected, but %v got", n)
+	}
+	if size := cache.Size(); size != 1 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 1 expected, but %v returned", size)
+	}
+}
+
+func TestGetEnsureAsync(t *testing.T) {
+	cache := lrucache.New[string, string](10, nil)
+	var creations int32
+	release := make(chan struct{})
+	create := func(key string) (value string, size uint) {
+		atomic.AddInt32(&creations, 1)
+		<-release
+		return "200", 1
+	}
+	cache.Put("key1", "100")
+	if value, ready := cache.GetEnsureAsync("key1", create); !ready || value != "100" {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsureAsync. \"100\", true expected, but %v, %v returned", value, ready)
+	}
+	for i := 0; i < 3; i++ {
+		if value, ready := cache.GetEnsureAsync("key2", create); ready || value != "" {
+			t.Fatalf("Wrong value returned by LruCache.GetEnsureAsync. \"\", false expected, but %v, %v returned", value, ready)
+		}
+	}
+	close(release)
+	deadline := time.Now().Add(time.Second)
+	for _, ok := cache.Get("key2"); !ok; _, ok = cache.Get("key2") {
+		if time.Now().After(deadline) {
+			t.Fatal("Value was not created by LruCache.GetEnsureAsync")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if value, ready := cache.GetEnsureAsync("key2", create); !ready || value != "200" {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsureAsync. \"200\", true expected, but %v, %v returned", value, ready)
+	}
+	if n := atomic.LoadInt32(&creations); n != 1 {
+		t.Fatalf("create called %v times, 1 expected", n)
+	}
+}
+
+func TestGetEnsureAsyncPanic(t *testing.T) {
+	recovered := make(chan any, 1)
+	cache := lrucache.New(10, nil, lrucache.WithCallbackPanicHandler[string, string](func(r any) { recovered <- r }))
+	cache.GetEnsureAsync("key", func(key string) (string, uint) { panic("create failed") })
+	if r := <-recovered; r != "create failed" {
+		t.Fatalf("Wrong panic. create failed expected, but %v got", r)
+	}
+	// No longer being created.
+	if value := cache.GetEnsure("key", func(key string) (string, uint) { return "value", 1 }); value != "value" {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsure. \"value\" expected, but %q returned", value)
+	}
+}
+
+func TestGetEnsureWithRemoved(t *testing.T) {
+	var globalRemoved, entryRemoved []int
+	cache := lrucache.New(2, func(key, oldValue, newValue int) {
+		globalRemoved = append(globalRemoved, oldValue)
+	})
+	create := func(key int) (value int, size uint) {
+		return key * 10, 1
+	}
+	onRemoved := func(newValue int) {
+		entryRemoved = append(entryRemoved, newValue)
+	}
+	if value := cache.GetEnsureWithRemoved(1, create, onRemoved); value != 10 {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsureWithRemoved. 10 expected, but %v returned", value)
+	}
+	cache.GetEnsureWithRemoved(2, create, onRemoved)
+	cache.GetEnsureWithRemoved(1, create, onRemoved) // Found, no new entry.
+	cache.Put(2, 200)                                // Replaced.
+	cache.Put(3, 30)                                 // Evicts 1.
+	if !reflect.DeepEqual(entryRemoved, []int{200, 0}) {
+		t.Fatalf("Wrong newValues passed to onRemoved. [200 0] expected, but %v got", entryRemoved)
+	}
+	if !reflect.DeepEqual(globalRemoved, []int{20, 10}) {
+		t.Fatalf("Wrong oldValues passed to EntryRemoved. [20 10] expected, but %v got", globalRemoved)
+	}
+	cache.Put(2, 2)
+	cache.Remove(2) // No onRemoved for the replacing value.
+	if len(entryRemoved) != 2 {
+		t.Fatalf("Wrong newValues passed to onRemoved. [200 0] expected, but %v got", entryRemoved)
 	}
 }
 
 func TestSize(t *testing.T) {
-	cache := lrucache.New(5, nil)
+	cache := lrucache.New[int, float64](5, nil)
 	if size := cache.Size(); size != 0 {
 		t.Fatal("Wrong size for empty cache")
 	}
@@ -56,7 +387,7 @@ func TestSize(t *testing.T) {
 	if size := cache.Size(); size != 1 {
 		t.Fatalf("Wrong value returned by LruCache.Size. 1 expected, but %v returned", size)
 	}
-	cache.PutSize("2", 22, 3)
+	cache.PutSize(2, 22, 3)
 	if size := cache.Size(); size != 4 {
 		t.Fatalf("Wrong value returned by LruCache.Size. 4 expected, but %v returned", size)
 	}
@@ -64,31 +395,286 @@ func TestSize(t *testing.T) {
 	if size := cache.Size(); size != 5 {
 		t.Fatalf("Wrong value returned by LruCache.Size. 5 expected, but %v returned", size)
 	}
-	if value := cache.Get(1); value != nil {
-		t.Fatalf("Wrong value returned by LruCache.Get. nil expected, but %v returned", value)
+	if value, ok := cache.Get(1); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+}
+
+func TestLen(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	if n := cache.Len(); n != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Len. 0 expected, but %v returned", n)
+	}
+	cache.PutSize(1, 1, 4)
+	cache.PutSize(2, 2, 3)
+	cache.PutSize(3, 3, 3)
+	if n, size := cache.Len(), cache.Size(); n != 3 || size != 10 {
+		t.Fatalf("Wrong value returned by LruCache.Len and LruCache.Size. 3, 10 expected, but %v, %v returned", n, size)
+	}
+	cache.PutSize(4, 4, 5) // Evicts 1 and 2.
+	if n, size := cache.Len(), cache.Size(); n != 2 || size != 8 {
+		t.Fatalf("Wrong value returned by LruCache.Len and LruCache.Size. 2, 8 expected, but %v, %v returned", n, size)
+	}
+}
+
+func TestPutWithPriority(t *testing.T) {
+	cache := lrucache.New[int, int](3, nil)
+	cache.PutWithPriority(1, 1, 1, 10)
+	cache.PutWithPriority(2, 2, 1, 5)
+	cache.Put(3, 3)
+	cache.Put(4, 4) // Evicts 3, the only one with priority 0.
+	if value, ok := cache.Get(3); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+	cache.PutWithPriority(5, 5, 1, 10) // Evicts 4.
+	cache.PutWithPriority(6, 6, 1, 10) // Evicts 2, the lowest priority.
+	for key, expected := range map[int]bool{1: true, 2: false, 4: false, 5: true, 6: true} {
+		if _, ok := cache.Get(key); ok != expected {
+			t.Fatalf("Wrong value returned by LruCache.Get(%v). %v expected, but %v returned", key, expected, ok)
+		}
+	}
+}
+
+func TestEvictionVeto(t *testing.T) {
+	protected := map[int]bool{1: true, 2: true}
+	cache := lrucache.New(3, nil, lrucache.WithEvictionVeto(func(key, value int, size uint) bool {
+		return !protected[key]
+	}))
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	cache.Put(3, 3)
+	cache.Put(4, 4) // Evicts 3.
+	if value, ok := cache.Get(3); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+	protected[4] = true
+	cache.Put(5, 5) // Evicts 5 itself.
+	if value, ok := cache.Get(5); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+	protected[5] = true
+	cache.Put(5, 5) // Everything vetoed.
+	if size := cache.Size(); size != 4 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 4 expected, but %v returned", size)
+	}
+	delete(protected, 1)
+	cache.Put(6, 6) // Evicts 1 and 6 to get back to maxSize.
+	if size := cache.Size(); size != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 3 expected, but %v returned", size)
+	}
+	if value, ok := cache.Get(1); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+}
+
+func TestMinResidency(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cache := lrucache.New(2, nil, lrucache.WithClock[int, int](clock.Now), lrucache.WithMinResidency[int, int](time.Minute))
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	cache.Put(3, 3) // Too young to evict anything.
+	if size := cache.Size(); size != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 3 expected, but %v returned", size)
+	}
+	clock.Advance(time.Minute)
+	cache.Put(4, 4) // Evicts 1 and 2.
+	if size := cache.Size(); size != 2 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 2 expected, but %v returned", size)
+	}
+	for key, expected := range map[int]bool{1: false, 2: false, 3: true, 4: true} {
+		if _, ok := cache.Get(key); ok != expected {
+			t.Fatalf("Wrong value returned by LruCache.Get(%v). %v expected, but %v returned", key, expected, ok)
+		}
+	}
+}
+
+func TestAgeHistogram(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cache := lrucache.New(10, nil, lrucache.WithClock[int, int](clock.Now))
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	clock.Advance(time.Minute)
+	cache.Put(3, 3)
+	cache.Put(1, 10) // Replacing does not change the age.
+	counts := cache.AgeHistogram([]time.Duration{time.Minute, time.Hour})
+	if !reflect.DeepEqual(counts, []int{1, 2, 0}) {
+		t.Fatalf("Wrong value returned by LruCache.AgeHistogram. [1 2 0] expected, but %v returned", counts)
+	}
+}
+
+func TestEvictionAgeStats(t *testing.T) {
+	cache := lrucache.New[int, int](2, nil)
+	if stats := cache.EvictionAgeStats(); stats != (lrucache.EvictionAgeStats{}) {
+		t.Fatalf("Wrong value returned by LruCache.EvictionAgeStats. Zero value expected, but %+v returned", stats)
+	}
+	cache.Put(1, 1)
+	time.Sleep(20 * time.Millisecond)
+	cache.Put(2, 2)
+	cache.Put(3, 3) // Evicts 1.
+	cache.Put(4, 4) // Evicts 2.
+	cache.Remove(3) // Not counted.
+	stats := cache.EvictionAgeStats()
+	if stats.Count != 2 || stats.Max < 20*time.Millisecond || stats.Mean < 10*time.Millisecond || stats.Mean > stats.Max {
+		t.Fatalf("Wrong value returned by LruCache.EvictionAgeStats: %+v", stats)
+	}
+}
+
+func TestEvictionPreview(t *testing.T) {
+	cache := lrucache.New[int, int](5, nil)
+	cache.PutSize(1, 1, 2)
+	cache.PutSize(2, 2, 1)
+	cache.PutSize(3, 3, 1)
+	if count, freed, victims := cache.EvictionPreview(4, 1); count != 0 || freed != 0 || victims != nil {
+		t.Fatalf("Wrong value returned by LruCache.EvictionPreview. 0, 0, [] expected, but %v, %v, %v returned", count, freed, victims)
+	}
+	if count, freed, victims := cache.EvictionPreview(4, 4); count != 2 || freed != 3 || !reflect.DeepEqual(victims, []int{1, 2}) {
+		t.Fatalf("Wrong value returned by LruCache.EvictionPreview. 2, 3, [1 2] expected, but %v, %v, %v returned", count, freed, victims)
+	}
+	// Replacing 1 with size 4 grows the cache by 2.
+	if count, freed, victims := cache.EvictionPreview(1, 4); count != 1 || freed != 1 || !reflect.DeepEqual(victims, []int{2}) {
+		t.Fatalf("Wrong value returned by LruCache.EvictionPreview. 1, 1, [2] expected, but %v, %v, %v returned", count, freed, victims)
+	}
+	if count, freed, victims := cache.EvictionPreview(1, 6); count != 3 || freed != 4 || !reflect.DeepEqual(victims, []int{2, 3, 1}) {
+		t.Fatalf("Wrong value returned by LruCache.EvictionPreview. 3, 4, [2 3 1] expected, but %v, %v, %v returned", count, freed, victims)
+	}
+	if size := cache.Size(); size != 4 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 4 expected, but %v returned", size)
+	}
+	cache.PutSize(1, 1, 6)
+	if size := cache.Size(); size != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 0 expected, but %v returned", size)
+	}
+}
+
+func TestOperationCount(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	cache.Put(1, 1)
+	cache.Get(1)
+	cache.GetEnsure(2, func(key int) (int, uint) { return 2, 1 })
+	cache.Remove(1)
+	cache.Size()
+	if count := cache.OperationCount(); count != 4 {
+		t.Fatalf("Wrong value returned by LruCache.OperationCount. 4 expected, but %v returned", count)
+	}
+}
+
+func TestKeyValidator(t *testing.T) {
+	cache := lrucache.New(10, nil, lrucache.WithKeyValidator[any, int](func(key any) error {
+		if _, ok := key.(string); !ok {
+			return errors.New("not a string")
+		}
+		return nil
+	}))
+	cache.Put("1", 1)
+	if value, _ := cache.Get("1"); value != 1 {
+		t.Fatalf("Wrong value returned by LruCache.Get. 1 expected, but %v returned", value)
+	}
+	for name, f := range map[string]func(){
+		"Get":    func() { cache.Get(1) },
+		"Put":    func() { cache.Put(nil, 1) },
+		"Remove": func() { cache.Remove(1.0) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("LruCache.%v should panic", name)
+				}
+			}()
+			f()
+		}()
 	}
 }
 
 func TestRemove(t *testing.T) {
-	cache := lrucache.New(5, nil)
+	cache := lrucache.New[int, int](5, nil)
 	cache.PutSize(1, 100, 4)
 	cache.Put(2, 200)
 	cache.Remove(1)
 	if size := cache.Size(); size != 1 {
 		t.Fatalf("Wrong value returned by LruCache.Size. 1 expected, but %v returned", size)
 	}
-	if value := cache.Get(1); value != nil {
-		t.Fatalf("Wrong value returned by LruCache.Get. nil expected, but \"%v\" returned", value)
+	if value, ok := cache.Get(1); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but \"%v\" returned", value)
+	}
+	if value, _ := cache.Get(2); value != 200 {
+		t.Fatalf("Wrong value returned by LruCache.Get. 200 expected, but \"%v\" returned", value)
+	}
+}
+
+func TestGetOldestNewest(t *testing.T) {
+	cache := lrucache.New[int, string](10, nil)
+	if _, _, ok := cache.GetOldest(); ok {
+		t.Fatal("Wrong value returned by LruCache.GetOldest. false expected for empty cache")
+	}
+	if _, _, ok := cache.GetNewest(); ok {
+		t.Fatal("Wrong value returned by LruCache.GetNewest. false expected for empty cache")
+	}
+	cache.Put(1, "1")
+	cache.Put(2, "2")
+	cache.Put(3, "3")
+	cache.Get(1)
+	for i := 0; i < 2; i++ { // Not promoted.
+		if key, value, ok := cache.GetOldest(); !ok || key != 2 || value != "2" {
+			t.Fatalf("Wrong value returned by LruCache.GetOldest. 2, \"2\", true expected, but %v, %q, %v returned", key, value, ok)
+		}
+		if key, value, ok := cache.GetNewest(); !ok || key != 1 || value != "1" {
+			t.Fatalf("Wrong value returned by LruCache.GetNewest. 1, \"1\", true expected, but %v, %q, %v returned", key, value, ok)
+		}
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{1, 3, 2}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [1 3 2] expected, but %v returned", keys)
+	}
+}
+
+func TestRemoveFunc(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(20, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	for i := 0; i < 6; i++ {
+		cache.PutSize(i, i, uint(i))
+	}
+	if n := cache.RemoveFunc(func(key, value int) bool { return key%2 == 0 }); n != 3 {
+		t.Fatalf("Wrong value returned by LruCache.RemoveFunc. 3 expected, but %v returned", n)
 	}
-	if value := cache.Get(2); value != 200 {
-		t.Fatalf("Wrong value returned by LruCache.Get. nil expected, but \"%v\" returned", value)
+	if !reflect.DeepEqual(removed, []int{4, 2, 0}) {
+		t.Fatalf("Wrong removed keys. [4 2 0] expected, but %v got", removed)
+	}
+	if keys, size := cache.Keys(), cache.Size(); !reflect.DeepEqual(keys, []int{5, 3, 1}) || size != 9 {
+		t.Fatalf("Wrong keys and size. [5 3 1], 9 expected, but %v, %v got", keys, size)
+	}
+}
+
+func TestClear(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(10, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+		if newValue != 0 {
+			t.Fatalf("Wrong newValue passed to EntryRemoved. 0 expected, but %v got", newValue)
+		}
+	})
+	for i := 1; i <= 5; i++ {
+		cache.PutSize(i, i, 2)
+	}
+	cache.Get(1)
+	cache.Clear()
+	if n, size := cache.Len(), cache.Size(); n != 0 || size != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Len and LruCache.Size. 0, 0 expected, but %v, %v returned", n, size)
+	}
+	if !reflect.DeepEqual(removed, []int{2, 3, 4, 5, 1}) {
+		t.Fatalf("Wrong removed keys. [2 3 4 5 1] expected, but %v got", removed)
+	}
+	cache.Put(1, 10)
+	if value, ok := cache.Get(1); !ok || value != 10 {
+		t.Fatalf("Wrong value returned by LruCache.Get. 10, true expected, but %v, %v returned", value, ok)
 	}
 }
 
 func TestCallback(t *testing.T) {
 	var fCalled bool
-	var removalKey, removalOldValue, removalNewValue interface{}
-	f := func(key, oldValue, newValue interface{}) {
+	var removalKey string
+	var removalOldValue, removalNewValue any
+	f := func(key string, oldValue, newValue any) {
 		fCalled = true
 		removalKey = key
 		removalOldValue = oldValue
@@ -110,7 +696,7 @@ func TestCallback(t *testing.T) {
 		t.Fatalf("true, \"1\", 1, nil expected, but %v, \"%v\", %v, %v got", fCalled, removalKey, removalOldValue, removalNewValue)
 	}
 	fCalled = false
-	removalKey = nil
+	removalKey = ""
 	removalOldValue = nil
 	removalNewValue = nil
 	cache.Put("3", 30)
@@ -120,7 +706,7 @@ func TestCallback(t *testing.T) {
 }
 
 func TestConcurrent(t *testing.T) {
-	cache := lrucache.New(20, nil)
+	cache := lrucache.New[int, string](20, nil)
 	waitGroup := &sync.WaitGroup{}
 
 	for i := 0; i < 100; i++ {
@@ -156,14 +742,14 @@ func TestConcurrent(t *testing.T) {
 	}
 
 	for i := 0; i < 100; i++ {
-		if value := cache.Get(i); value != nil {
-			t.Fatalf("Wrong value returned by LruCache.Get. nil expected, but  \"%v\" returned", value)
+		if value, ok := cache.Get(i); ok {
+			t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but  \"%v\" returned", value)
 		}
 	}
 	var remainCount uint
 	for i := 101; i < 300; i++ {
 		str := strconv.Itoa(i)
-		if value := cache.Get(i); value != nil {
+		if value, ok := cache.Get(i); ok {
 			remainCount++
 			if value != str {
 				t.Fatalf("Wrong value returned by LruCache.Get. \"%v\" expected, but \"%v\" returned", str, value)
@@ -171,18 +757,257 @@ func TestConcurrent(t *testing.T) {
 		}
 	}
 	if remainCount != cache.MaxSize() {
-		t.Fatalf("Wrong remainCount. %v expected, but %v got", cache.MaxSize, remainCount)
+		t.Fatalf("Wrong remainCount. %v expected, but %v got", cache.MaxSize(), remainCount)
+	}
+}
+
+// valuePool records the values put into it.
+type valuePool []any
+
+func (pool *valuePool) Put(x any) {
+	*pool = append(*pool, x)
+}
+
+func TestValuePool(t *testing.T) {
+	var pool valuePool
+	cache := lrucache.New(2, nil, lrucache.WithValuePool[int, string](&pool))
+	cache.Put(1, "1")
+	cache.Put(2, "2")
+	cache.Put(2, "20") // Replaced, not pooled.
+	cache.Remove(2)    // Removed, not pooled.
+	cache.Put(3, "3")
+	cache.Put(4, "4") // Evicts (1, "1").
+	cache.Put(5, "5") // Evicts (3, "3").
+	if expected := (valuePool{"1", "3"}); !reflect.DeepEqual(pool, expected) {
+		t.Fatalf("Wrong pooled values. %v expected, but %v got", expected, pool)
+	}
+	if value, _ := cache.Get(4); value != "4" {
+		t.Fatalf("Wrong value returned by LruCache.Get. \"4\" expected, but \"%v\" returned", value)
+	}
+	// *sync.Pool is a ValuePool.
+	lrucache.New(2, nil, lrucache.WithValuePool[int, string](&sync.Pool{}))
+}
+
+func TestGetAndGrow(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(5, func(key int, oldValue, newValue []byte) {
+		removed = append(removed, key)
+	})
+	cache.PutSize(1, []byte("a"), 1)
+	cache.PutSize(2, []byte("b"), 1)
+	grow := func(value []byte) ([]byte, uint) {
+		buf := append(value, "bcd"...)
+		return buf, uint(len(buf))
+	}
+	if _, ok := cache.GetAndGrow(3, grow); ok {
+		t.Fatal("GetAndGrow should fail for absent key")
+	}
+	if value, ok := cache.GetAndGrow(1, grow); !ok || string(value) != "abcd" {
+		t.Fatalf("Wrong value returned by LruCache.GetAndGrow. \"abcd\", true expected, but \"%s\", %v returned", value, ok)
+	}
+	if size := cache.Size(); size != 5 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 5 expected, but %v returned", size)
+	}
+	cache.GetAndGrow(1, grow) // Grows to 7, evicts 2 and then 1 itself.
+	if size := cache.Size(); size != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 0 expected, but %v returned", size)
+	}
+	if len(removed) != 2 || removed[0] != 2 || removed[1] != 1 {
+		t.Fatalf("Wrong removed keys. [2 1] expected, but %v got", removed)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	var mutex sync.Mutex // Replaced values are reported by the concurrent updates.
+	var removed []string
+	cache := lrucache.New(10, func(key string, oldValue, newValue int) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		removed = append(removed, fmt.Sprintf("%v:%v", key, oldValue))
+	})
+	increment := func(oldValue int, existed bool) (int, uint, bool) {
+		return oldValue + 1, 1, true
+	}
+	const goroutines, increments = 8, 1000
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				cache.Update("counter", increment)
+			}
+		}()
+	}
+	wg.Wait()
+	if value, ok := cache.Peek("counter"); !ok || value != goroutines*increments {
+		t.Fatalf("Wrong value returned by LruCache.Peek. %v, true expected, but %v, %v returned", goroutines*increments, value, ok)
+	}
+
+	removed = nil
+	if value, ok := cache.Update("counter", func(oldValue int, existed bool) (int, uint, bool) {
+		return 0, 0, false
+	}); ok || value != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Update. 0, false expected, but %v, %v returned", value, ok)
+	}
+	if cache.Contains("counter") {
+		t.Fatal("Wrong value returned by LruCache.Contains. false expected for removed entry")
+	}
+	if !reflect.DeepEqual(removed, []string{fmt.Sprintf("counter:%v", goroutines*increments)}) {
+		t.Fatalf("Wrong removed entries. [counter:%v] expected, but %v got", goroutines*increments, removed)
+	}
+	cache.Update("absent", func(oldValue int, existed bool) (int, uint, bool) {
+		if existed {
+			t.Fatal("Wrong existed passed to the function of LruCache.Update. false expected")
+		}
+		return 0, 0, false
+	})
+	if cache.Len() != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Len. 0 expected, but %v returned", cache.Len())
+	}
+}
+
+func TestFuncPanicUnlocks(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	cache.Put(1, 1)
+	for name, f := range map[string]func(){
+		"GetAndGrow": func() { cache.GetAndGrow(1, func(value int) (int, uint) { panic("grow failed") }) },
+		"Update":     func() { cache.Update(1, func(oldValue int, existed bool) (int, uint, bool) { panic("update failed") }) },
+		"RemoveFunc": func() { cache.RemoveFunc(func(key, value int) bool { panic("pred failed") }) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Fatalf("LruCache.%v should panic", name)
+				}
+			}()
+			f()
+		}()
+		if value, _ := cache.Get(1); value != 1 { // Not deadlocked.
+			t.Fatalf("Wrong value returned by LruCache.Get after LruCache.%v panicked. 1 expected, but %v returned", name, value)
+		}
+	}
+}
+
+func TestEstimatedMemory(t *testing.T) {
+	if memory := lrucache.New[int, string](10, nil).EstimatedMemory(); memory != 0 {
+		t.Fatalf("Wrong value returned by LruCache.EstimatedMemory. 0 expected, but %v returned", memory)
+	}
+	cache := lrucache.New(100, nil, lrucache.WithMemorySampler(func(key int, value string) uint {
+		return uint(len(value))
+	}, 3))
+	if memory := cache.EstimatedMemory(); memory != 0 {
+		t.Fatalf("Wrong value returned by LruCache.EstimatedMemory. 0 expected, but %v returned", memory)
+	}
+	for i := 0; i < 10; i++ {
+		cache.Put(i, "0123456789")
+	}
+	if memory := cache.EstimatedMemory(); memory != 100 {
+		t.Fatalf("Wrong value returned by LruCache.EstimatedMemory. 100 expected, but %v returned", memory)
+	}
+}
+
+func TestKeyMemory(t *testing.T) {
+	cache := lrucache.New[any, int](10, nil)
+	cache.Put("key1", 1)
+	cache.Put("key01", 2)
+	cache.Put(3, 3)
+	cache.Put(string([]byte("key1")), 10) // Equal but distinct key string.
+	if memory := cache.KeyMemory(); memory != 9 {
+		t.Fatalf("Wrong value returned by LruCache.KeyMemory. 9 expected, but %v returned", memory)
+	}
+}
+
+func TestPromotionThreshold(t *testing.T) {
+	cache := lrucache.New(3, nil, lrucache.WithPromotionThreshold[int, int](2))
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	cache.Put(3, 3) // Queue: | 3 2 1
+	cache.Get(1)
+	cache.Get(1)    // Promoted. Queue: 1 | 3 2
+	cache.Get(3)    // Not promoted yet.
+	cache.Put(4, 4) // Evicts 2. Queue: 1 | 4 3
+	if value, ok := cache.Get(2); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+	cache.Put(5, 5) // Evicts 3. Queue: 1 | 5 4
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{1, 5, 4}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [1 5 4] expected, but %v returned", keys)
+	}
+	cache.Get(4)
+	cache.Get(4) // Promoted. Queue: 4 1 | 5
+	cache.Get(5)
+	cache.Get(5)    // Promoted. Demotes 1, the protected segment being at most 2. Queue: 5 4 | 1
+	cache.Put(6, 6) // Evicts 1. Queue: 5 4 | 6
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{5, 4, 6}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [5 4 6] expected, but %v returned", keys)
+	}
+}
+
+// TestPromotionThresholdWorkingSet changes the working set and checks that the cache follows.
+func TestPromotionThresholdWorkingSet(t *testing.T) {
+	cache := lrucache.New(8, nil, lrucache.WithPromotionThreshold[string, int](2))
+	creates := 0
+	create := func(key string) (int, uint) {
+		creates++
+		return 0, 1
+	}
+	run := func(keys ...string) (hits int) {
+		for i := 0; i < 1000; i++ {
+			before := creates
+			cache.GetEnsure(keys[i%len(keys)], create)
+			if creates == before {
+				hits++
+			}
+		}
+		return
+	}
+	for _, key := range []string{"x", "y", "z"} {
+		cache.GetEnsure(key, create) // Never used again.
+	}
+	// Each new working set is missed once per key, and found ever since.
+	for _, keys := range [][]string{{"a", "b", "c", "d", "e", "f"}, {"g", "h"}, {"i", "j"}} {
+		if hits := run(keys...); hits != 1000-len(keys) {
+			t.Fatalf("Wrong hits of the working set %v. %v expected, but %v got", keys, 1000-len(keys), hits)
+		}
+	}
+	if hits := run("g", "h", "i", "j"); hits != 1000 {
+		t.Fatalf("Wrong hits of the working set [g h i j]. 1000 expected, but %v got", hits)
+	}
+	for _, key := range []string{"x", "y", "z"} {
+		if cache.Contains(key) {
+			t.Fatalf("Wrong value returned by LruCache.Contains(%q). false expected for an old working set", key)
+		}
 	}
 }
 
 func BenchmarkPut(b *testing.B) {
-	cache := lrucache.New(2000, nil)
+	cache := lrucache.New[int, int](2000, nil)
 	for i := 0; i < b.N; i++ {
 		cache.Put(i%300, i)
 	}
 }
 
-var cacheForBenchmarkGet = lrucache.New(2000, nil)
+// benchmarkWarmUp fills a cache of 1GB with entries of 1MB.
+func benchmarkWarmUp(b *testing.B, options ...lrucache.Option[int, int]) {
+	const entrySize = 1 << 20
+	for i := 0; i < b.N; i++ {
+		cache := lrucache.New(1<<30, nil, options...)
+		for key := 0; key < 1<<30/entrySize; key++ {
+			cache.PutSize(key, key, entrySize)
+		}
+	}
+}
+
+func BenchmarkWarmUp(b *testing.B) {
+	benchmarkWarmUp(b)
+}
+
+func BenchmarkWarmUpExpectedEntries(b *testing.B) {
+	benchmarkWarmUp(b, lrucache.WithExpectedEntries[int, int](1<<30/(1<<20)))
+}
+
+var cacheForBenchmarkGet = lrucache.New[int, int](2000, nil)
 
 func init() {
 	for i := 0; i < 1200; i++ {
@@ -195,3 +1020,669 @@ func BenchmarkGet(b *testing.B) {
 		cacheForBenchmarkGet.Get(i)
 	}
 }
+
+// benchmarkKeys puts and gets keys in a cache keyed by K, e.g. a concrete type or an interface.
+// Caches keyed by a concrete type such as int or string need no specialized version: the map of LruCache
+// is instantiated with K, so the keys are not boxed.
+func benchmarkKeys[K comparable](b *testing.B, keys []K) {
+	cache := lrucache.New[K, int](uint(len(keys)), nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := keys[i%len(keys)]
+		cache.Get(key)
+		cache.Put(key, i)
+	}
+}
+
+func BenchmarkGetPutIntKeys(b *testing.B) {
+	keys := make([]int, 1000)
+	for i := range keys {
+		keys[i] = i
+	}
+	benchmarkKeys(b, keys)
+}
+
+func BenchmarkGetPutStringKeys(b *testing.B) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	benchmarkKeys(b, keys)
+}
+
+func BenchmarkGetPutInterfaceKeys(b *testing.B) {
+	keys := make([]any, 1000)
+	for i := range keys {
+		if i%2 == 0 {
+			keys[i] = i
+		} else {
+			keys[i] = strconv.Itoa(i)
+		}
+	}
+	benchmarkKeys(b, keys)
+}
+
+// BenchmarkSizeWithWriters reads Size from many goroutines while others put.
+func BenchmarkSizeWithWriters(b *testing.B) {
+	cache := lrucache.New[int, int](1000, nil)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+					cache.Put(i%2000, i)
+				}
+			}
+		}()
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			cache.Size()
+		}
+	})
+	b.StopTimer()
+	close(stop)
+	wg.Wait()
+}
+
+func TestGetNoPromote(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(2, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	cache.Put(1, 10)
+	cache.Put(2, 20)
+	if value, ok := cache.GetNoPromote(1); !ok || value != 10 {
+		t.Fatalf("Wrong value returned by LruCache.GetNoPromote. 10, true expected, but %v, %v returned", value, ok)
+	}
+	if _, ok := cache.GetNoPromote(3); ok {
+		t.Fatal("Wrong value returned by LruCache.GetNoPromote. Nothing expected")
+	}
+	cache.Put(3, 30) // Evicts 1 since GetNoPromote does not promote it.
+	if !reflect.DeepEqual(removed, []int{1}) {
+		t.Fatalf("Wrong removed keys. [1] expected, but %v got", removed)
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("Wrong value returned by LruCache.Stats. 1 hit and 1 miss expected, but %+v returned", stats)
+	}
+}
+
+func TestContains(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(2, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	if !cache.Contains(1) || cache.Contains(3) {
+		t.Fatal("Wrong value returned by LruCache.Contains")
+	}
+	cache.Put(3, 3) // Evicts 1 since Contains does not promote it.
+	if !reflect.DeepEqual(removed, []int{1}) {
+		t.Fatalf("Wrong removed keys. [1] expected, but %v got", removed)
+	}
+}
+
+func TestTryPutSize(t *testing.T) {
+	cache := lrucache.New[int, int](5, nil)
+	cache.Put(1, 1)
+	if _, _, ok := cache.TryPutSize(2, 2, 6); ok {
+		t.Fatal("Wrong value returned by LruCache.TryPutSize. false expected for size exceeding maxSize")
+	}
+	if _, _, ok := cache.TryPutSize(1, 10, 6); ok {
+		t.Fatal("Wrong value returned by LruCache.TryPutSize. false expected for size exceeding maxSize")
+	}
+	if value, ok := cache.Get(1); !ok || value != 1 || cache.Len() != 1 {
+		t.Fatalf("Wrong value returned by LruCache.Get. 1, true expected, but %v, %v returned", value, ok)
+	}
+	if oldValue, replaced, ok := cache.TryPutSize(1, 10, 5); !ok || !replaced || oldValue != 1 {
+		t.Fatalf("Wrong value returned by LruCache.TryPutSize. 1, true, true expected, but %v, %v, %v returned", oldValue, replaced, ok)
+	}
+	if _, replaced, ok := cache.TryPutSize(2, 20, 5); !ok || replaced {
+		t.Fatalf("Wrong value returned by LruCache.TryPutSize. false, true expected, but %v, %v returned", replaced, ok)
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{2}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [2] expected, but %v returned", keys)
+	}
+}
+
+func TestPutIfAbsent(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	var wg sync.WaitGroup
+	var inserted atomic.Int32
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if actual, loaded := cache.PutIfAbsent(0, i, 1); !loaded {
+				inserted.Add(1)
+				if actual != i {
+					t.Errorf("Wrong value returned by LruCache.PutIfAbsent. %v expected, but %v returned", i, actual)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := inserted.Load(); n != 1 {
+		t.Fatalf("Wrong number of insertions by LruCache.PutIfAbsent. 1 expected, but %v got", n)
+	}
+	value, _ := cache.Get(0)
+	if actual, loaded := cache.PutIfAbsent(0, -1, 1); !loaded || actual != value {
+		t.Fatalf("Wrong value returned by LruCache.PutIfAbsent. %v, true expected, but %v, %v returned", value, actual, loaded)
+	}
+}
+
+func TestGetOrPut(t *testing.T) {
+	cache := lrucache.New[int, int](2, nil)
+	var wg sync.WaitGroup
+	var inserted atomic.Int32
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, loaded := cache.GetOrPut(0, i, 1); !loaded {
+				inserted.Add(1)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := inserted.Load(); n != 1 {
+		t.Fatalf("Wrong number of insertions by LruCache.GetOrPut. 1 expected, but %v got", n)
+	}
+	if stats := cache.Stats(); stats.Hits != 99 || stats.Misses != 1 {
+		t.Fatalf("Wrong value returned by LruCache.Stats. 99 hits and 1 miss expected, but %+v returned", stats)
+	}
+	cache.Put(1, 1)
+	value, _ := cache.Peek(0)
+	if actual, loaded := cache.GetOrPut(0, -1, 1); !loaded || actual != value {
+		t.Fatalf("Wrong value returned by LruCache.GetOrPut. %v, true expected, but %v, %v returned", value, actual, loaded)
+	}
+	cache.Put(2, 2) // Evicts 1 since 0 was promoted.
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{2, 0}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [2 0] expected, but %v returned", keys)
+	}
+}
+
+func TestUpdateSize(t *testing.T) {
+	var removed []string
+	cache := lrucache.New(5, func(key string, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Put("buf", 3)
+	if !cache.UpdateSize("buf", 3) {
+		t.Fatal("Wrong value returned by LruCache.UpdateSize. true expected")
+	}
+	if size := cache.Size(); size != 5 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 5 expected, but %v returned", size)
+	}
+	cache.UpdateSize("buf", 4) // Evicts "a".
+	cache.UpdateSize("buf", 5) // Evicts "b".
+	if !reflect.DeepEqual(removed, []string{"a", "b"}) {
+		t.Fatalf("Wrong removed keys. [a b] expected, but %v got", removed)
+	}
+	cache.UpdateSize("buf", 1)
+	cache.Put("c", 4)
+	cache.UpdateSize("c", 5) // Evicts "buf", the least recently used.
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"c"}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [c] expected, but %v returned", keys)
+	}
+	if cache.UpdateSize("x", 1) {
+		t.Fatal("Wrong value returned by LruCache.UpdateSize. false expected")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	var removed []string
+	cache := lrucache.New(5, func(key string, oldValue, newValue int) {
+		removed = append(removed, fmt.Sprintf("%v:%v->%v", key, oldValue, newValue))
+	})
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Put("c", 3)
+	if oldValue, ok := cache.Replace("a", 10, 1); !ok || oldValue != 1 {
+		t.Fatalf("Wrong value returned by LruCache.Replace. 1, true expected, but %v, %v returned", oldValue, ok)
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"c", "b", "a"}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [c b a] expected, but %v returned", keys)
+	}
+	if value, ok := cache.Peek("a"); !ok || value != 10 {
+		t.Fatalf("Wrong value returned by LruCache.Peek. 10, true expected, but %v, %v returned", value, ok)
+	}
+	if _, ok := cache.Replace("x", 1, 1); ok {
+		t.Fatal("Wrong value returned by LruCache.Replace. false expected for absent key")
+	}
+	if cache.Contains("x") {
+		t.Fatal("Wrong value returned by LruCache.Contains. false expected, Replace must not insert")
+	}
+	cache.Replace("b", 20, 4) // Evicts "a", still the least recently used.
+	if !reflect.DeepEqual(removed, []string{"a:1->10", "b:2->20", "a:10->0"}) {
+		t.Fatalf("Wrong removed entries. [a:1->10 b:2->20 a:10->0] expected, but %v got", removed)
+	}
+	if size := cache.Size(); size != 5 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 5 expected, but %v returned", size)
+	}
+}
+
+func TestEvictionPreviewVeto(t *testing.T) {
+	cache := lrucache.New(3, nil, lrucache.WithEvictionVeto(func(key, value int, size uint) bool { return key != 1 }))
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	// 1 is vetoed, so 2 and then the new entry itself are evicted.
+	if count, freed, victims := cache.EvictionPreview(3, 3); count != 2 || freed != 1 || !reflect.DeepEqual(victims, []int{2, 3}) {
+		t.Fatalf("Wrong value returned by LruCache.EvictionPreview. 2, 1, [2 3] expected, but %v, %v, %v returned", count, freed, victims)
+	}
+	cache.PutSize(3, 3, 3)
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{1}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [1] expected, but %v returned", keys)
+	}
+}
+
+// checkEvictionPreview fills random caches created by newCache, and checks that EvictionPreview agrees
+// with the entries evicted by the PutSize previewed.
+func checkEvictionPreview(t *testing.T, newCache func(entryRemoved lrucache.EntryRemovedReason[int, int]) *lrucache.LruCache[int, int]) {
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		var evicted []int
+		cache := newCache(func(key, oldValue, newValue int, reason lrucache.Reason) {
+			if reason == lrucache.ReasonEvicted {
+				evicted = append(evicted, key)
+			}
+		})
+		for j := random.Intn(50); j > 0; j-- {
+			key := random.Intn(20)
+			if random.Intn(2) == 0 {
+				cache.Get(key)
+			} else {
+				cache.PutSize(key, key, uint(1+random.Intn(4)))
+			}
+		}
+		evicted = nil
+		key, size := random.Intn(20), uint(1+random.Intn(8))
+		count, _, victims := cache.EvictionPreview(key, size)
+		cache.PutSize(key, key, size)
+		if count != len(evicted) || !reflect.DeepEqual(victims, evicted) {
+			t.Fatalf("Wrong value returned by LruCache.EvictionPreview(%v, %v). %v expected, but %v returned", key, size, evicted, victims)
+		}
+	}
+}
+
+func TestEvictionPreviewModes(t *testing.T) {
+	veto := func(key, value int, size uint) bool { return key%3 != 0 }
+	for name, options := range map[string][]lrucache.Option[int, int]{
+		"LRU":                {},
+		"Veto":               {lrucache.WithEvictionVeto(veto)},
+		"PromotionThreshold": {lrucache.WithPromotionThreshold[int, int](2)},
+		"PromotionVeto":      {lrucache.WithPromotionThreshold[int, int](2), lrucache.WithEvictionVeto(veto)},
+		"LFU":                {lrucache.WithPolicy[int, int](lrucache.PolicyLFU)},
+		"LFUVeto":            {lrucache.WithPolicy[int, int](lrucache.PolicyLFU), lrucache.WithEvictionVeto(veto)},
+		"2Q":                 {lrucache.WithPolicy[int, int](lrucache.Policy2Q)},
+		"2QVeto":             {lrucache.WithPolicy[int, int](lrucache.Policy2Q), lrucache.WithEvictionVeto(veto)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			checkEvictionPreview(t, func(entryRemoved lrucache.EntryRemovedReason[int, int]) *lrucache.LruCache[int, int] {
+				return lrucache.New(16, nil, append(options, lrucache.WithEntryRemovedReason(entryRemoved))...)
+			})
+		})
+	}
+}
+
+func TestSizeOverflow(t *testing.T) {
+	var removed []string
+	cache := lrucache.New(math.MaxUint, nil,
+		lrucache.WithEntryRemovedReason(func(key string, oldValue, newValue int, reason lrucache.Reason) {
+			removed = append(removed, fmt.Sprintf("%v:%v", key, reason))
+		}),
+		lrucache.WithEvictionVeto(func(key string, value int, size uint) bool { return key != "pinned" }))
+	cache.PutSize("a", 1, 10)
+	cache.PutSize("b", 2, 10)
+	if count, _, victims := cache.EvictionPreview("big", math.MaxUint-15); count != 1 || !reflect.DeepEqual(victims, []string{"a"}) {
+		t.Fatalf("Wrong value returned by LruCache.EvictionPreview. 1, [a] expected, but %v, %v returned", count, victims)
+	}
+	cache.PutSize("big", 3, math.MaxUint-15) // Evicts "a" first, or the size would overflow.
+	if size := cache.Size(); size != math.MaxUint-5 {
+		t.Fatalf("Wrong value returned by LruCache.Size. %v expected, but %v returned", uint(math.MaxUint-5), size)
+	}
+	if !cache.UpdateSize("b", 16) { // Evicts "big" first.
+		t.Fatal("Wrong value returned by LruCache.UpdateSize. true expected")
+	}
+	cache.PutSize("pinned", 4, 10)
+	if oldValue, ok := cache.Replace("b", 20, math.MaxUint); !ok || oldValue != 2 {
+		t.Fatalf("Wrong value returned by LruCache.Replace. 2, true expected, but %v, %v returned", oldValue, ok)
+	}
+	// "pinned" can not be evicted to make room.
+	if oldValue, replaced := cache.PutSize("huge", 5, math.MaxUint); replaced {
+		t.Fatalf("Wrong value returned by LruCache.PutSize. Nothing expected, but %v, %v returned", oldValue, replaced)
+	}
+	cache.UpdateSize("pinned", math.MaxUint)
+	expected := []string{"a:evicted", "big:evicted", "b:replaced", "b:discarded", "huge:discarded"}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("Wrong removals reported to EntryRemovedReason. %v expected, but %v got", expected, removed)
+	}
+	if size := cache.Size(); size != math.MaxUint {
+		t.Fatalf("Wrong value returned by LruCache.Size. %v expected, but %v returned", uint(math.MaxUint), size)
+	}
+}
+
+func TestGetAndGrowSizeOverflow(t *testing.T) {
+	var removed []string
+	cache := lrucache.New(math.MaxUint, nil,
+		lrucache.WithEntryRemovedReason(func(key string, oldValue, newValue string, reason lrucache.Reason) {
+			removed = append(removed, fmt.Sprintf("%v:%v->%v:%v", key, oldValue, newValue, reason))
+		}))
+	cache.PutSize("a", "A", 10)
+	cache.PutSize("b", "B", 10)
+	if value, ok := cache.GetAndGrow("b", func(value string) (string, uint) { return value + value, math.MaxUint - 5 }); !ok || value != "BB" {
+		t.Fatalf("Wrong value returned by LruCache.GetAndGrow. BB, true expected, but %v, %v returned", value, ok)
+	}
+	// "a" is evicted first, or the size would overflow. Growing "b" is not a replacement.
+	if expected := []string{"a:A->:evicted"}; !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("Wrong removals reported to EntryRemovedReason. %v expected, but %v got", expected, removed)
+	}
+	if size := cache.Size(); size != math.MaxUint-5 {
+		t.Fatalf("Wrong value returned by LruCache.Size. %v expected, but %v returned", uint(math.MaxUint-5), size)
+	}
+}
+
+func TestTouch(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(2, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	if !cache.Touch(1) || cache.Touch(3) {
+		t.Fatal("Wrong value returned by LruCache.Touch")
+	}
+	cache.Put(3, 3) // Evicts 2 since 1 was touched.
+	if !reflect.DeepEqual(removed, []int{2}) {
+		t.Fatalf("Wrong removed keys. [2] expected, but %v got", removed)
+	}
+}
+
+func TestSizer(t *testing.T) {
+	var removed []string
+	cache := lrucache.New(10, func(key string, oldValue, newValue []byte) {
+		removed = append(removed, key)
+	}, lrucache.WithSizer(func(key string, value []byte) uint { return uint(len(value)) }))
+	cache.Put("a", make([]byte, 3))
+	cache.Put("b", make([]byte, 4))
+	cache.Put("c", make([]byte, 2))
+	if size := cache.Size(); size != 9 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 9 expected, but %v returned", size)
+	}
+	cache.Put("c", make([]byte, 1)) // Replaced.
+	cache.Put("d", make([]byte, 5)) // Evicts "a".
+	if !reflect.DeepEqual(removed, []string{"c", "a"}) {
+		t.Fatalf("Wrong removed keys. [c a] expected, but %v got", removed)
+	}
+	if size := cache.Size(); size != 10 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 10 expected, but %v returned", size)
+	}
+}
+
+func TestPopOldest(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(10, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	var popped []int
+	pop := func() {
+		if key, value, ok := cache.PopOldest(); !ok || value != key*10 {
+			t.Fatalf("Wrong value returned by LruCache.PopOldest. %v, %v, true expected, but %v, %v, %v returned", key, key*10, key, value, ok)
+		} else {
+			popped = append(popped, key)
+		}
+	}
+	cache.Put(1, 10)
+	cache.Put(2, 20)
+	cache.Put(3, 30)
+	pop()
+	cache.Get(2)
+	cache.Put(4, 40)
+	pop()
+	pop()
+	cache.Put(5, 50)
+	pop()
+	pop()
+	if !reflect.DeepEqual(popped, []int{1, 3, 2, 4, 5}) {
+		t.Fatalf("Wrong popped keys. [1 3 2 4 5] expected, but %v got", popped)
+	}
+	if _, _, ok := cache.PopOldest(); ok {
+		t.Fatal("Wrong value returned by LruCache.PopOldest. false expected for empty cache")
+	}
+	if size := cache.Size(); size != 0 || len(removed) != 0 {
+		t.Fatalf("Wrong size or removed keys. 0, [] expected, but %v, %v got", size, removed)
+	}
+}
+
+func TestTrimToSize(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(10, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	for i := 0; i < 5; i++ {
+		cache.PutSize(i, i, 2)
+	}
+	cache.Get(0)
+	if n := cache.TrimToSize(5); n != 3 {
+		t.Fatalf("Wrong value returned by LruCache.TrimToSize. 3 expected, but %v returned", n)
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{0, 4}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [0 4] expected, but %v returned", keys)
+	}
+	if n := cache.TrimToSize(0); n != 2 {
+		t.Fatalf("Wrong value returned by LruCache.TrimToSize. 2 expected, but %v returned", n)
+	}
+	if !reflect.DeepEqual(removed, []int{1, 2, 3, 4, 0}) {
+		t.Fatalf("Wrong removed keys. [1 2 3 4 0] expected, but %v got", removed)
+	}
+	if maxSize := cache.MaxSize(); maxSize != 10 {
+		t.Fatalf("Wrong value returned by LruCache.MaxSize. 10 expected, but %v returned", maxSize)
+	}
+}
+
+func TestEntryRemovedReentrant(t *testing.T) {
+	var cache *lrucache.LruCache[int, int]
+	cache = lrucache.New(3, func(key, oldValue, newValue int) {
+		if key > 0 {
+			cache.Put(-key, oldValue) // Evicts more.
+		} else {
+			cache.Remove(key)
+			cache.Contains(-key)
+		}
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= 10; i++ {
+			cache.Put(i, i)
+			cache.GetEnsure(i+100, func(key int) (int, uint) { return key, 1 })
+			cache.Remove(i)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Deadlock in EntryRemoved calling the cache")
+	}
+	if size, n := cache.Size(), cache.Len(); size != uint(n) || size > 3 {
+		t.Fatalf("Inconsistent size %v and len %v", size, n)
+	}
+}
+
+func TestCallbackPanicHandler(t *testing.T) {
+	var recovered []any
+	var removed []int
+	cache := lrucache.New(1, func(key, oldValue, newValue int) {
+		if key == 1 {
+			panic("bad entry")
+		}
+		removed = append(removed, key)
+	}, lrucache.WithCallbackPanicHandler[int, int](func(r any) {
+		recovered = append(recovered, r)
+	}))
+	cache.Put(1, 1)
+	cache.Put(2, 2) // Evicts 1.
+	cache.Remove(2)
+	if !reflect.DeepEqual(recovered, []any{"bad entry"}) {
+		t.Fatalf("Wrong recovered values. [bad entry] expected, but %v got", recovered)
+	}
+	if !reflect.DeepEqual(removed, []int{2}) {
+		t.Fatalf("Wrong removed keys. [2] expected, but %v got", removed)
+	}
+	if n, size := cache.Len(), cache.Size(); n != 0 || size != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Len and LruCache.Size. 0, 0 expected, but %v, %v returned", n, size)
+	}
+
+	cache = lrucache.New(1, func(key, oldValue, newValue int) { panic("bad entry") })
+	cache.Put(1, 1)
+	defer func() {
+		if r := recover(); r != "bad entry" {
+			t.Fatalf("Wrong panic. bad entry expected, but %v got", r)
+		}
+		if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{2}) {
+			t.Fatalf("Wrong value returned by LruCache.Keys. [2] expected, but %v returned", keys)
+		}
+	}()
+	cache.Put(2, 2)
+}
+
+func TestNewWithEntries(t *testing.T) {
+	var removed []string
+	cache := lrucache.NewWithEntries(5, func(key string, oldValue, newValue int) {
+		removed = append(removed, fmt.Sprintf("%v:%v", key, oldValue))
+	}, []lrucache.Entry[string, int]{{"a", 1, 1}, {"b", 2, 2}, {"a", 10, 1}, {"c", 3, 1}, {"d", 4, 2}})
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"a", "b", "c"}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [a b c] expected, but %v returned", keys)
+	}
+	if value, ok := cache.Peek("a"); !ok || value != 1 {
+		t.Fatalf("Wrong value returned by LruCache.Peek. 1, true expected, but %v, %v returned", value, ok)
+	}
+	if !reflect.DeepEqual(removed, []string{"a:10", "d:4"}) {
+		t.Fatalf("Wrong removed entries. [a:10 d:4] expected, but %v got", removed)
+	}
+	if size := cache.Size(); size != 4 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 4 expected, but %v returned", size)
+	}
+}
+
+func TestNewCount(t *testing.T) {
+	var removed []int
+	cache := lrucache.NewCount(3, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	cache.Put(1, 1)
+	cache.PutSize(2, 2, 100)
+	cache.GetEnsure(3, func(key int) (int, uint) { return 3, 50 })
+	if size, n := cache.Size(), cache.Len(); size != 3 || n != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Size and LruCache.Len. 3, 3 expected, but %v, %v returned", size, n)
+	}
+	cache.UpdateSize(2, 10)
+	if _, _, ok := cache.TryPutSize(4, 4, 10); !ok {
+		t.Fatal("Wrong value returned by LruCache.TryPutSize. true expected, sizes are ignored")
+	}
+	if !reflect.DeepEqual(removed, []int{1}) {
+		t.Fatalf("Wrong removed keys. [1] expected, but %v got", removed)
+	}
+	if size := cache.Size(); size != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 3 expected, but %v returned", size)
+	}
+}
+
+func TestNewCountSizes(t *testing.T) {
+	cache := lrucache.NewCount(10, nil, lrucache.WithSizer(func(key, value int) uint { return 100 }))
+	cache.Put(1, 1)
+	cache.PutSize(2, 2, 0)
+	cache.PutWithTTL(3, 3, 100, time.Hour)
+	cache.PutSizeMulti([]lrucache.Entry[int, int]{{Key: 4, Value: 4, Size: 100}, {Key: 5, Value: 5, Size: 0}})
+	cache.ApplyBatch([]lrucache.Op[int, int]{{Kind: lrucache.OpPut, Key: 6, Value: 6, Size: 100}})
+	cache.GetAndGrow(1, func(value int) (int, uint) { return 10, 100 })
+	cache.Replace(2, 20, 100)
+	cache.Update(7, func(oldValue int, existed bool) (int, uint, bool) { return 7, 0, true })
+	cache.UpdateSize(3, 0)
+	// Every entry, whatever its size, counts as 1.
+	if size, n := cache.Size(), cache.Len(); size != 7 || n != 7 {
+		t.Fatalf("Wrong value returned by LruCache.Size and LruCache.Len. 7, 7 expected, but %v, %v returned", size, n)
+	}
+	for key := 1; key <= 7; key++ {
+		if !cache.Contains(key) {
+			t.Fatalf("Wrong value returned by LruCache.Contains(%v). true expected", key)
+		}
+	}
+}
+
+func TestSetMaxSize(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(5, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	for i := 0; i < 5; i++ {
+		cache.Put(i, i)
+	}
+	cache.Get(0)
+	cache.SetMaxSize(2)
+	if !reflect.DeepEqual(removed, []int{1, 2, 3}) {
+		t.Fatalf("Wrong removed keys. [1 2 3] expected, but %v got", removed)
+	}
+	if maxSize, size := cache.MaxSize(), cache.Size(); maxSize != 2 || size != 2 {
+		t.Fatalf("Wrong value returned by LruCache.MaxSize and LruCache.Size. 2, 2 expected, but %v, %v returned", maxSize, size)
+	}
+	cache.SetMaxSize(10)
+	cache.Put(5, 5)
+	if size := cache.Size(); size != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 3 expected, but %v returned", size)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("LruCache.SetMaxSize(0) should panic")
+		}
+	}()
+	cache.SetMaxSize(0)
+}
+
+// benchmarkScanHitRatio reports the hit ratio of a warmed up hot working set interleaved with a scan of keys used once.
+func benchmarkScanHitRatio(b *testing.B, options ...lrucache.Option[int, int]) {
+	cache := lrucache.New(100, nil, options...)
+	for i := 0; i < 3; i++ {
+		for hot := 0; hot < 50; hot++ {
+			if _, ok := cache.Get(hot); !ok {
+				cache.Put(hot, hot)
+			}
+		}
+	}
+	var hits, gets int
+	for i := 0; i < b.N; i++ {
+		for hot := 0; hot < 50; hot++ {
+			gets++
+			if _, ok := cache.Get(hot); ok {
+				hits++
+			} else {
+				cache.Put(hot, hot)
+			}
+		}
+		for scan := 0; scan < 100; scan++ {
+			key := -(i*100 + scan + 1)
+			if _, ok := cache.Get(key); !ok {
+				cache.Put(key, key)
+			}
+		}
+	}
+	b.ReportMetric(float64(hits)/float64(gets), "hot-hit-ratio")
+}
+
+func BenchmarkScanHitRatio(b *testing.B) {
+	benchmarkScanHitRatio(b)
+}
+
+func BenchmarkScanHitRatioPromotionThreshold(b *testing.B) {
+	benchmarkScanHitRatio(b, lrucache.WithPromotionThreshold[int, int](2))
+}
@@ -0,0 +1,71 @@
+// Package lrucacheotel exports the metrics of a lrucache.LruCache to OpenTelemetry.
+// It is a module of its own, so the lrucache module does not depend on OpenTelemetry.
+package lrucacheotel
+
+import (
+	"context"
+	"github.com/mkch/lrucache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// Source is the cache read by Register. *lrucache.LruCache[K, V] implements it for any K and V.
+type Source interface {
+	Size() uint
+	Len() int
+	MaxSize() uint
+	Stats() lrucache.Stats
+}
+
+// Register creates the asynchronous instruments of the metrics of cache with meter,
+// and registers a callback observing them with attrs, e.g. attribute.String("cache", "users"),
+// to tell the caches sharing meter apart:
+//
+//	lrucache.size       Gauge, sum of entry sizes, see LruCache.Size.
+//	lrucache.entries    Gauge, number of entries, see LruCache.Len.
+//	lrucache.max_size   Gauge, maximum size, see LruCache.MaxSize.
+//	lrucache.hits       Counter, see lrucache.Stats.
+//	lrucache.misses     Counter, see lrucache.Stats.
+//	lrucache.evictions  Counter, see lrucache.Stats.
+//
+// The counters restart from zero after LruCache.ResetStats.
+// Call Unregister of the returned Registration to stop observing cache.
+func Register(meter metric.Meter, cache Source, attrs ...attribute.KeyValue) (metric.Registration, error) {
+	size, err := meter.Int64ObservableGauge("lrucache.size", metric.WithDescription("Sum of the entry sizes of the cache."))
+	if err != nil {
+		return nil, err
+	}
+	entries, err := meter.Int64ObservableGauge("lrucache.entries", metric.WithDescription("Number of entries in the cache."))
+	if err != nil {
+		return nil, err
+	}
+	maxSize, err := meter.Int64ObservableGauge("lrucache.max_size", metric.WithDescription("Maximum size of the cache."))
+	if err != nil {
+		return nil, err
+	}
+	hits, err := meter.Int64ObservableCounter("lrucache.hits", metric.WithDescription("Number of lookups finding a value."))
+	if err != nil {
+		return nil, err
+	}
+	misses, err := meter.Int64ObservableCounter("lrucache.misses", metric.WithDescription("Number of lookups finding nothing."))
+	if err != nil {
+		return nil, err
+	}
+	evictions, err := meter.Int64ObservableCounter("lrucache.evictions", metric.WithDescription("Number of entries evicted to make space."))
+	if err != nil {
+		return nil, err
+	}
+
+	set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+	// Each value is read by a separate call to the cache, which holds the lock of the cache only briefly or not at all.
+	return meter.RegisterCallback(func(ctx context.Context, observer metric.Observer) error {
+		stats := cache.Stats()
+		observer.ObserveInt64(size, int64(cache.Size()), set)
+		observer.ObserveInt64(entries, int64(cache.Len()), set)
+		observer.ObserveInt64(maxSize, int64(cache.MaxSize()), set)
+		observer.ObserveInt64(hits, int64(stats.Hits), set)
+		observer.ObserveInt64(misses, int64(stats.Misses), set)
+		observer.ObserveInt64(evictions, int64(stats.Evictions), set)
+		return nil
+	}, size, entries, maxSize, hits, misses, evictions)
+}
@@ -0,0 +1,68 @@
+package lrucacheotel_test
+
+import (
+	"context"
+	"github.com/mkch/lrucache"
+	"github.com/mkch/lrucache/lrucacheotel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"reflect"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	cache := lrucache.New[int, int](3, nil)
+	cache.PutSize(1, 1, 2)
+	cache.Put(2, 2)
+	cache.Put(3, 3) // Evicts 1.
+	cache.Get(2)
+	cache.Get(1)
+
+	reader := sdkmetric.NewManualReader()
+	meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+	registration, err := lrucacheotel.Register(meter, cache, attribute.String("name", "users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer registration.Unregister()
+
+	var data metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &data); err != nil {
+		t.Fatal(err)
+	}
+	values := make(map[string]int64)
+	for _, scope := range data.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			var points []metricdata.DataPoint[int64]
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				points = data.DataPoints
+			case metricdata.Sum[int64]:
+				if !data.IsMonotonic {
+					t.Fatalf("Wrong kind of %v. Monotonic sum expected", m.Name)
+				}
+				points = data.DataPoints
+			default:
+				t.Fatalf("Wrong data of %v. Int64 gauge or sum expected, but %T got", m.Name, m.Data)
+			}
+			for _, point := range points {
+				if name, _ := point.Attributes.Value("name"); name.AsString() != "users" {
+					t.Fatalf("Wrong attributes of %v. name=users expected, but %v got", m.Name, point.Attributes.Encoded(attribute.DefaultEncoder()))
+				}
+				values[m.Name] = point.Value
+			}
+		}
+	}
+	expected := map[string]int64{
+		"lrucache.size":      2,
+		"lrucache.entries":   2,
+		"lrucache.max_size":  3,
+		"lrucache.hits":      1,
+		"lrucache.misses":    1,
+		"lrucache.evictions": 1,
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatalf("Wrong metrics observed. %v expected, but %v got", expected, values)
+	}
+}
@@ -0,0 +1,71 @@
+// Package lrucacheprom exports the metrics of a lrucache.LruCache to Prometheus.
+// It is a module of its own, so the lrucache module does not depend on Prometheus.
+package lrucacheprom
+
+import (
+	"github.com/mkch/lrucache"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Source is the cache read by a Collector. *lrucache.LruCache[K, V] implements it for any K and V.
+type Source interface {
+	Size() uint
+	Len() int
+	MaxSize() uint
+	Stats() lrucache.Stats
+}
+
+// Collector is a prometheus.Collector of the metrics of a cache:
+//
+//	<prefix>_size             Sum of entry sizes, see LruCache.Size.
+//	<prefix>_entries          Number of entries, see LruCache.Len.
+//	<prefix>_max_size         Maximum size, see LruCache.MaxSize.
+//	<prefix>_hits_total       See lrucache.Stats.
+//	<prefix>_misses_total     See lrucache.Stats.
+//	<prefix>_evictions_total  See lrucache.Stats.
+//
+// The counters restart from zero after LruCache.ResetStats, which Prometheus treats as a counter reset.
+type Collector struct {
+	cache                             Source
+	size, entries, maxSize            *prometheus.Desc
+	hitsTotal, missesTotal, evictions *prometheus.Desc
+}
+
+// NewCollector creates a Collector of the metrics of cache, named with prefix and labeled with constLabels.
+// Register it with a prometheus.Registerer to export the metrics.
+func NewCollector(cache Source, prefix string, constLabels prometheus.Labels) *Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(prefix, "", name), help, nil, constLabels)
+	}
+	return &Collector{
+		cache:       cache,
+		size:        desc("size", "Sum of the entry sizes of the cache."),
+		entries:     desc("entries", "Number of entries in the cache."),
+		maxSize:     desc("max_size", "Maximum size of the cache."),
+		hitsTotal:   desc("hits_total", "Number of lookups finding a value."),
+		missesTotal: desc("misses_total", "Number of lookups finding nothing."),
+		evictions:   desc("evictions_total", "Number of entries evicted to make space."),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (collector *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.size
+	ch <- collector.entries
+	ch <- collector.maxSize
+	ch <- collector.hitsTotal
+	ch <- collector.missesTotal
+	ch <- collector.evictions
+}
+
+// Collect implements prometheus.Collector.
+// Each value is read by a separate call to the cache, which holds the lock of the cache only briefly or not at all.
+func (collector *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := collector.cache.Stats()
+	ch <- prometheus.MustNewConstMetric(collector.size, prometheus.GaugeValue, float64(collector.cache.Size()))
+	ch <- prometheus.MustNewConstMetric(collector.entries, prometheus.GaugeValue, float64(collector.cache.Len()))
+	ch <- prometheus.MustNewConstMetric(collector.maxSize, prometheus.GaugeValue, float64(collector.cache.MaxSize()))
+	ch <- prometheus.MustNewConstMetric(collector.hitsTotal, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(collector.missesTotal, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(collector.evictions, prometheus.CounterValue, float64(stats.Evictions))
+}
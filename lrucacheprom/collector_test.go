@@ -0,0 +1,45 @@
+package lrucacheprom_test
+
+import (
+	"github.com/mkch/lrucache"
+	"github.com/mkch/lrucache/lrucacheprom"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	cache := lrucache.New[int, int](3, nil)
+	cache.PutSize(1, 1, 2)
+	cache.Put(2, 2)
+	cache.Put(3, 3) // Evicts 1.
+	cache.Get(2)
+	cache.Get(1)
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(lrucacheprom.NewCollector(cache, "myapp_cache", prometheus.Labels{"name": "users"}))
+	expected := `
+# HELP myapp_cache_entries Number of entries in the cache.
+# TYPE myapp_cache_entries gauge
+myapp_cache_entries{name="users"} 2
+# HELP myapp_cache_evictions_total Number of entries evicted to make space.
+# TYPE myapp_cache_evictions_total counter
+myapp_cache_evictions_total{name="users"} 1
+# HELP myapp_cache_hits_total Number of lookups finding a value.
+# TYPE myapp_cache_hits_total counter
+myapp_cache_hits_total{name="users"} 1
+# HELP myapp_cache_max_size Maximum size of the cache.
+# TYPE myapp_cache_max_size gauge
+myapp_cache_max_size{name="users"} 3
+# HELP myapp_cache_misses_total Number of lookups finding nothing.
+# TYPE myapp_cache_misses_total counter
+myapp_cache_misses_total{name="users"} 1
+# HELP myapp_cache_size Sum of the entry sizes of the cache.
+# TYPE myapp_cache_size gauge
+myapp_cache_size{name="users"} 2
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
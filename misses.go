@@ -0,0 +1,55 @@
+package lrucache
+
+import "sort"
+
+// KeyCount is a key and a count.
+type KeyCount[K comparable] struct {
+	Key   K
+	Count uint64
+}
+
+// missCounts is the state of WithMissCounts.
+type missCounts[K comparable] struct {
+	counts *LruCache[K, *uint64]
+}
+
+// WithMissCounts makes the cache count misses per key, for MissCounts.
+// Counts are kept for at most maxKeys keys, cached or not. When the table is full, the counts of the keys
+// missed least recently are dropped, so keys missed rarely may be undercounted.
+func WithMissCounts[K comparable, V any](maxKeys uint) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.missCounts = &missCounts[K]{counts: New[K, *uint64](maxKeys, nil)}
+	}
+}
+
+// missed counts a miss of key.
+func (misses *missCounts[K]) missed(key K) {
+	if count, ok := misses.counts.GetLocal(key); ok {
+		*count++
+		return
+	}
+	count := uint64(1)
+	misses.counts.Put(key, &count)
+}
+
+// MissCounts returns up to topN keys with the most misses, in descending order of counts.
+// Misses are counted by the lookups counting Stats.Misses.
+// It returns nil if WithMissCounts was not used or topN is not positive.
+// Keys missed often but cached rarely may benefit from a larger cache or from a higher priority (see PutWithPriority).
+func (cache *LruCache[K, V]) MissCounts(topN int) (counts []KeyCount[K]) {
+	if cache.missCounts == nil || topN <= 0 {
+		return nil
+	}
+	cache.mutex.RLock()
+	cache.missCounts.counts.View().Range(func(key K, count *uint64) bool {
+		counts = append(counts, KeyCount[K]{key, *count})
+		return true
+	})
+	cache.mutex.RUnlock()
+
+	sort.SliceStable(counts, func(i, j int) bool { return counts[i].Count > counts[j].Count })
+	if len(counts) > topN {
+		counts = counts[:topN]
+	}
+	return
+}
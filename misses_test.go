@@ -0,0 +1,33 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"reflect"
+	"testing"
+)
+
+func TestMissCounts(t *testing.T) {
+	if counts := lrucache.New[string, int](1, nil).MissCounts(10); counts != nil {
+		t.Fatalf("Wrong value returned by LruCache.MissCounts. nil expected, but %v returned", counts)
+	}
+	cache := lrucache.New(1, nil, lrucache.WithMissCounts[string, int](3))
+	for i := 0; i < 3; i++ {
+		cache.Get("a")
+		cache.Put("a", 1)
+		cache.Get("b")
+		cache.Put("b", 2)
+	}
+	cache.Get("a")
+	cache.Get("c")
+	cache.Get("d") // Drops the count of "b", the least recently missed.
+	cache.Get("d")
+	expected := []lrucache.KeyCount[string]{{"a", 4}, {"d", 2}}
+	if counts := cache.MissCounts(2); !reflect.DeepEqual(counts, expected) {
+		t.Fatalf("Wrong value returned by LruCache.MissCounts. %v expected, but %v returned", expected, counts)
+	}
+	for _, topN := range []int{0, -1} {
+		if counts := cache.MissCounts(topN); counts != nil {
+			t.Fatalf("Wrong value returned by LruCache.MissCounts(%v). nil expected, but %v returned", topN, counts)
+		}
+	}
+}
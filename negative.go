@@ -0,0 +1,56 @@
+package lrucache
+
+import "time"
+
+// GetEnsureNeg does similar work as GetEnsure except create can report that key has no value by returning
+// found false, and the result is cached for a time depending on it: posTTL for a value found,
+// negTTL for a "not found", see PutWithTTL. Zero means never expire.
+// found is false if the cached result is a "not found", in which case create is not called again
+// until it expires or a value for key is put. Other methods, such as Get, see a "not found" as the value
+// returned with it by create, usually the zero value.
+// Unlike GetEnsure, concurrent misses of the same key are not coalesced: each caller calls create,
+// and the result of the first to finish is cached and returned to the others.
+func (cache *LruCache[K, V]) GetEnsureNeg(key K, create func(key K) (value V, size uint, found bool), negTTL, posTTL time.Duration) (value V, found bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	cache.mutex.Lock()
+	element, removals := cache.lookup(key)
+	cache.lookedUp(key, element != nil)
+	if element != nil {
+		cache.hit(element)
+		entry := element.Value.(*entry[K, V])
+		value, found = entry.v, !entry.negative
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	if element != nil {
+		return
+	}
+
+	value, size, found := create(key)
+	ttl := posTTL
+	if !found {
+		ttl = negTTL
+	}
+
+	cache.mutex.Lock()
+	element, removals = cache.lookup(key)
+	if element != nil {
+		// Lost the race. Discard.
+		removals = append(removals, removal[K, V]{key: key, oldValue: value, reason: ReasonDiscarded})
+		entry := element.Value.(*entry[K, V])
+		value, found = entry.v, !entry.negative
+	} else {
+		_, _, putRemovals := cache.putSize(key, value, size, 0)
+		removals = append(removals, putRemovals...)
+		if element := cache.m[key]; element != nil {
+			entry := element.Value.(*entry[K, V])
+			entry.expires = cache.expiry(ttl)
+			entry.negative = !found
+		}
+		cache.expiring = cache.expiring || ttl > 0
+	}
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
@@ -0,0 +1,44 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"testing"
+	"time"
+)
+
+func TestGetEnsureNeg(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	cache := lrucache.New[string, int](10, nil, lrucache.WithClock[string, int](clock.Now))
+	calls := make(map[string]int)
+	load := func(key string) (value int, size uint, found bool) {
+		calls[key]++
+		if key == "missing" {
+			return 0, 1, false
+		}
+		return len(key), 1, true
+	}
+	get := func(key string, expectedValue int, expectedFound bool, expectedCalls int) {
+		t.Helper()
+		value, found := cache.GetEnsureNeg(key, load, time.Minute, time.Hour)
+		if value != expectedValue || found != expectedFound {
+			t.Fatalf("Wrong value returned by LruCache.GetEnsureNeg(%q). %v, %v expected, but %v, %v returned", key, expectedValue, expectedFound, value, found)
+		}
+		if calls[key] != expectedCalls {
+			t.Fatalf("Wrong number of create calls for %q. %v expected, but %v got", key, expectedCalls, calls[key])
+		}
+	}
+
+	get("missing", 0, false, 1)
+	get("found", 5, true, 1)
+	clock.Advance(59 * time.Second)
+	get("missing", 0, false, 1) // Cached "not found".
+	get("found", 5, true, 1)
+	clock.Advance(time.Second)
+	get("missing", 0, false, 2) // Expired after negTTL.
+	get("found", 5, true, 1)    // Cached for posTTL.
+	clock.Advance(time.Hour)
+	get("found", 5, true, 2)
+
+	cache.Put("missing", 100)
+	get("missing", 100, true, 2)
+}
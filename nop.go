@@ -0,0 +1,73 @@
+package lrucache
+
+// Cache is the common interface of LruCache, ShardedLruCache and NopCache,
+// so callers can switch between them, e.g. to measure whether caching helps.
+type Cache[K comparable, V any] interface {
+	Get(key K) (value V, ok bool)
+	GetEnsure(key K, create CreateEntry[K, V]) (value V)
+	Put(key K, value V) (oldValue V, replaced bool)
+	PutSize(key K, value V, size uint) (oldValue V, replaced bool)
+	Remove(key K) (value V, ok bool)
+	Size() uint
+	MaxSize() uint
+}
+
+// NopCache is a Cache which caches nothing: every get misses and every put is dropped.
+// A value put, or created by GetEnsure, is treated as an entry larger than the cache, evicted at once:
+// the EntryRemoved function is called with it as oldValue and the zero newValue before the method returns,
+// so values holding resources are released as they would be by LruCache.
+type NopCache[K comparable, V any] struct {
+	entryRemoved EntryRemoved[K, V]
+}
+
+// NewNop creates a NopCache. entryRemoved, if not nil, is called for every value put. See NopCache.
+func NewNop[K comparable, V any](entryRemoved EntryRemoved[K, V]) *NopCache[K, V] {
+	return &NopCache[K, V]{entryRemoved: entryRemoved}
+}
+
+// Get returns the zero value and false.
+func (cache *NopCache[K, V]) Get(key K) (value V, ok bool) {
+	return
+}
+
+// GetEnsure returns the value created by create, which is dropped at once.
+func (cache *NopCache[K, V]) GetEnsure(key K, create CreateEntry[K, V]) (value V) {
+	value, _ = create(key)
+	cache.drop(key, value)
+	return
+}
+
+// Put drops value and returns the zero value and false.
+func (cache *NopCache[K, V]) Put(key K, value V) (oldValue V, replaced bool) {
+	cache.drop(key, value)
+	return
+}
+
+// PutSize drops value and returns the zero value and false.
+func (cache *NopCache[K, V]) PutSize(key K, value V, size uint) (oldValue V, replaced bool) {
+	cache.drop(key, value)
+	return
+}
+
+// Remove returns the zero value and false.
+func (cache *NopCache[K, V]) Remove(key K) (value V, ok bool) {
+	return
+}
+
+// Size returns 0.
+func (cache *NopCache[K, V]) Size() uint {
+	return 0
+}
+
+// MaxSize returns 0.
+func (cache *NopCache[K, V]) MaxSize() uint {
+	return 0
+}
+
+// drop calls the EntryRemoved function for value.
+func (cache *NopCache[K, V]) drop(key K, value V) {
+	if cache.entryRemoved != nil {
+		var zero V
+		cache.entryRemoved(key, value, zero)
+	}
+}
@@ -0,0 +1,45 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"reflect"
+	"testing"
+)
+
+var (
+	_ lrucache.Cache[string, int] = (*lrucache.LruCache[string, int])(nil)
+	_ lrucache.Cache[string, int] = (*lrucache.ShardedLruCache[string, int])(nil)
+	_ lrucache.Cache[string, int] = (*lrucache.NopCache[string, int])(nil)
+)
+
+func TestNopCache(t *testing.T) {
+	var removed []string
+	var cache lrucache.Cache[string, int] = lrucache.NewNop(func(key string, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	if oldValue, replaced := cache.Put("a", 1); replaced {
+		t.Fatalf("Wrong value returned by NopCache.Put. Nothing expected, but %v, %v returned", oldValue, replaced)
+	}
+	cache.PutSize("a", 2, 10)
+	if value, ok := cache.Get("a"); ok {
+		t.Fatalf("Wrong value returned by NopCache.Get. Nothing expected, but %v returned", value)
+	}
+	creates := 0
+	for i := 0; i < 2; i++ {
+		if value := cache.GetEnsure("b", func(key string) (int, uint) { creates++; return 3, 1 }); value != 3 {
+			t.Fatalf("Wrong value returned by NopCache.GetEnsure. 3 expected, but %v returned", value)
+		}
+	}
+	if creates != 2 {
+		t.Fatalf("Wrong number of create calls. 2 expected, but %v got", creates)
+	}
+	if value, ok := cache.Remove("a"); ok {
+		t.Fatalf("Wrong value returned by NopCache.Remove. Nothing expected, but %v returned", value)
+	}
+	if size, maxSize := cache.Size(), cache.MaxSize(); size != 0 || maxSize != 0 {
+		t.Fatalf("Wrong size of NopCache. 0, 0 expected, but %v, %v got", size, maxSize)
+	}
+	if !reflect.DeepEqual(removed, []string{"a", "a", "b", "b"}) {
+		t.Fatalf("Wrong removed keys. [a a b b] expected, but %v got", removed)
+	}
+}
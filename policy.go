@@ -0,0 +1,244 @@
+package lrucache
+
+import "container/list"
+
+// Policy is the eviction policy of a cache, see WithPolicy.
+type Policy int
+
+const (
+	// PolicyLRU evicts the least recently used entry. It is the default.
+	PolicyLRU Policy = iota
+	// PolicyLFU evicts the least frequently used entry, and the least recently used one among entries used
+	// equally often. An entry is used when it is added or replaced, found by Get and the other getting methods,
+	// or touched by Touch.
+	PolicyLFU
+	// Policy2Q is the 2Q algorithm, which resists scans of keys used only once. A new key is added to
+	// a FIFO queue, A1in, of at most a quarter of maxSize. Entries overflowing A1in are evicted first, and their
+	// keys are remembered in A1out, a queue of at most maxSize/2 keys. A key found in A1out when added again
+	// is added to Am, the LRU queue of the other entries, which is evicted from once A1in fits.
+	// Finding an entry in A1in does not reorder it.
+	Policy2Q
+)
+
+// WithPolicy sets the eviction policy of the cache. The other options affecting eviction, such as
+// WithEvictionVeto, apply to the entries in the order of the policy.
+// The order of Keys, Range and the other methods iterating entries is still the order of use.
+func WithPolicy[K comparable, V any](policy Policy) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.policy = policy
+		cache.lfu, cache.twoQ = nil, nil
+		switch policy {
+		case PolicyLFU:
+			cache.lfu = list.New()
+		case Policy2Q:
+			cache.twoQ = &twoQ[K]{a1in: list.New(), am: list.New(), a1out: list.New(), a1outKeys: make(map[K]*list.Element)}
+		}
+	}
+}
+
+// policyAdd adds the new element to the structures of the policy. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) policyAdd(element *list.Element) {
+	if cache.policy == PolicyLFU {
+		cache.lfuAdd(element)
+	} else {
+		cache.twoQAdd(element)
+	}
+}
+
+// policyUse records a use of element. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) policyUse(element *list.Element) {
+	if cache.policy == PolicyLFU {
+		cache.lfuUse(element)
+	} else if entry := element.Value.(*entry[K, V]); !entry.inA1in {
+		cache.twoQ.am.MoveToFront(entry.policyElement)
+	}
+}
+
+// policyRemove removes element, which left the cache for reason, from the structures of the policy.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) policyRemove(element *list.Element, reason Reason) {
+	if cache.policy == PolicyLFU {
+		cache.lfuRemove(element)
+	} else {
+		cache.twoQRemove(element, reason)
+	}
+}
+
+// policyClear removes all entries from the structures of the policy. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) policyClear() {
+	if cache.policy == PolicyLFU {
+		cache.lfu.Init()
+	} else {
+		cache.twoQ.a1in.Init()
+		cache.twoQ.am.Init()
+		cache.twoQ.inSize = 0
+	}
+}
+
+// evictionFirst returns the first element in eviction order, or nil if the cache is empty.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) evictionFirst() *list.Element {
+	return cache.evictionFirstIn(cache.twoQInSize())
+}
+
+// evictionFirstIn is the same as evictionFirst except the order of Policy2Q is decided as if inSize were
+// the sum of entry sizes in A1in. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) evictionFirstIn(inSize uint) *list.Element {
+	switch cache.policy {
+	case PolicyLFU:
+		if front := cache.lfu.Front(); front != nil {
+			return front.Value.(*lfuBucket).elements.Back().Value.(*list.Element)
+		}
+		return nil
+	case Policy2Q:
+		first, second := cache.twoQOrder(inSize)
+		if back := first.Back(); back != nil {
+			return back.Value.(*list.Element)
+		}
+		if back := second.Back(); back != nil {
+			return back.Value.(*list.Element)
+		}
+		return nil
+	}
+	return cache.l.Back()
+}
+
+// evictionNext returns the element after element in eviction order, or nil if element is the last.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) evictionNext(element *list.Element) *list.Element {
+	return cache.evictionNextIn(element, cache.twoQInSize())
+}
+
+// evictionNextIn is the same as evictionNext except the order of Policy2Q is decided as if inSize were
+// the sum of entry sizes in A1in. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) evictionNextIn(element *list.Element, inSize uint) *list.Element {
+	entry := element.Value.(*entry[K, V])
+	switch cache.policy {
+	case PolicyLFU:
+		if prev := entry.policyElement.Prev(); prev != nil {
+			return prev.Value.(*list.Element)
+		}
+		if next := entry.lfuBucket.Next(); next != nil {
+			return next.Value.(*lfuBucket).elements.Back().Value.(*list.Element)
+		}
+		return nil
+	case Policy2Q:
+		if prev := entry.policyElement.Prev(); prev != nil {
+			return prev.Value.(*list.Element)
+		}
+		first, second := cache.twoQOrder(inSize)
+		if entry.inA1in == (first == cache.twoQ.a1in) {
+			if back := second.Back(); back != nil {
+				return back.Value.(*list.Element)
+			}
+		}
+		return nil
+	}
+	return element.Prev()
+}
+
+// lfuBucket is the entries used freq times, an element of LruCache.lfu, which is ordered by freq ascending.
+type lfuBucket struct {
+	freq     uint
+	elements *list.List // Elements of LruCache.l, the most recently used at the front.
+}
+
+// lfuAdd adds element used once to the LFU buckets. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) lfuAdd(element *list.Element) {
+	front := cache.lfu.Front()
+	if front == nil || front.Value.(*lfuBucket).freq != 1 {
+		front = cache.lfu.PushFront(&lfuBucket{freq: 1, elements: list.New()})
+	}
+	entry := element.Value.(*entry[K, V])
+	entry.lfuBucket = front
+	entry.policyElement = front.Value.(*lfuBucket).elements.PushFront(element)
+}
+
+// lfuUse moves element to the bucket of the next frequency. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) lfuUse(element *list.Element) {
+	entry := element.Value.(*entry[K, V])
+	bucket := entry.lfuBucket
+	freq := bucket.Value.(*lfuBucket).freq + 1
+	next := bucket.Next()
+	if next == nil || next.Value.(*lfuBucket).freq != freq {
+		next = cache.lfu.InsertAfter(&lfuBucket{freq: freq, elements: list.New()}, bucket)
+	}
+	cache.lfuRemove(element)
+	entry.lfuBucket = next
+	entry.policyElement = next.Value.(*lfuBucket).elements.PushFront(element)
+}
+
+// lfuRemove removes element from the LFU buckets. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) lfuRemove(element *list.Element) {
+	entry := element.Value.(*entry[K, V])
+	elements := entry.lfuBucket.Value.(*lfuBucket).elements
+	elements.Remove(entry.policyElement)
+	if elements.Len() == 0 {
+		cache.lfu.Remove(entry.lfuBucket)
+	}
+	entry.lfuBucket, entry.policyElement = nil, nil
+}
+
+// twoQ is the queues of Policy2Q.
+type twoQ[K comparable] struct {
+	a1in      *list.List // Elements of LruCache.l, the newest at the front.
+	am        *list.List // Elements of LruCache.l, the most recently used at the front.
+	inSize    uint       // The sum of entry sizes in a1in.
+	a1out     *list.List // Keys, the newest at the front.
+	a1outKeys map[K]*list.Element
+}
+
+// twoQInSize returns the sum of entry sizes in A1in, or 0 if the policy is not Policy2Q.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) twoQInSize() uint {
+	if cache.twoQ == nil {
+		return 0
+	}
+	return cache.twoQ.inSize
+}
+
+// twoQOrder returns the queue to evict from first and the other queue, if the sum of entry sizes in A1in is inSize.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) twoQOrder(inSize uint) (first, second *list.List) {
+	if inSize > cache.maxSize/4 {
+		return cache.twoQ.a1in, cache.twoQ.am
+	}
+	return cache.twoQ.am, cache.twoQ.a1in
+}
+
+// twoQAdd adds element to Am if its key is in A1out, or to A1in otherwise. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) twoQAdd(element *list.Element) {
+	q := cache.twoQ
+	entry := element.Value.(*entry[K, V])
+	if out := q.a1outKeys[entry.k]; out != nil {
+		q.a1out.Remove(out)
+		delete(q.a1outKeys, entry.k)
+		entry.policyElement = q.am.PushFront(element)
+		return
+	}
+	entry.policyElement = q.a1in.PushFront(element)
+	entry.inA1in = true
+	q.inSize += entry.size
+}
+
+// twoQRemove removes element from its queue. The key of an entry evicted from A1in is remembered in A1out.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) twoQRemove(element *list.Element, reason Reason) {
+	q := cache.twoQ
+	entry := element.Value.(*entry[K, V])
+	if !entry.inA1in {
+		q.am.Remove(entry.policyElement)
+		entry.policyElement = nil
+		return
+	}
+	q.a1in.Remove(entry.policyElement)
+	q.inSize -= entry.size
+	entry.policyElement, entry.inA1in = nil, false
+	if reason != ReasonEvicted {
+		return
+	}
+	q.a1outKeys[entry.k] = q.a1out.PushFront(entry.k)
+	for uint(q.a1out.Len()) > max(cache.maxSize/2, 1) {
+		delete(q.a1outKeys, q.a1out.Remove(q.a1out.Back()).(K))
+	}
+}
@@ -0,0 +1,76 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"reflect"
+	"testing"
+)
+
+func TestPolicyLFU(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(3, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	}, lrucache.WithPolicy[int, int](lrucache.PolicyLFU))
+	cache.Put(0, 0)
+	for i := 0; i < 10; i++ {
+		cache.Get(0)
+	}
+	cache.Put(1, 1)
+	cache.Put(2, 2)
+	cache.Get(2)
+	for key := 3; key < 6; key++ {
+		cache.Put(key, key) // Evicts 1, then the previous key.
+	}
+	if !reflect.DeepEqual(removed, []int{1, 3, 4}) {
+		t.Fatalf("Wrong removed keys. [1 3 4] expected, but %v got", removed)
+	}
+	for key, expected := range map[int]bool{0: true, 2: true, 5: true} {
+		if _, ok := cache.Peek(key); ok != expected {
+			t.Fatalf("Wrong value returned by LruCache.Peek(%v). %v expected, but %v returned", key, expected, ok)
+		}
+	}
+
+	cache.Touch(5)
+	cache.Put(6, 6) // Evicts the least recently used 2 of frequency 2.
+	if removed[len(removed)-1] != 2 {
+		t.Fatalf("Wrong removed key. 2 expected, but %v got", removed[len(removed)-1])
+	}
+	cache.Clear()
+	cache.Put(7, 7)
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{7}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [7] expected, but %v returned", keys)
+	}
+}
+
+// loopHits replays rounds of a hot set of 6 keys, each followed by a scan of 6 keys used once, on a cache of size 10,
+// getting each key and putting it on a miss, and returns the number of hot set hits after the first 2 rounds.
+// The hot set and a scan do not fit together, so LRU evicts the hot set in each round.
+func loopHits(options ...lrucache.Option[int, int]) (hits int) {
+	cache := lrucache.New(10, nil, options...)
+	scan := 1000
+	for round := 0; round < 10; round++ {
+		for hot := 0; hot < 6; hot++ {
+			if _, ok := cache.Get(hot); !ok {
+				cache.Put(hot, hot)
+			} else if round >= 2 {
+				hits++
+			}
+		}
+		for i := 0; i < 6; i++ {
+			if _, ok := cache.Get(scan); !ok {
+				cache.Put(scan, scan)
+			}
+			scan++
+		}
+	}
+	return
+}
+
+func TestPolicy2Q(t *testing.T) {
+	if hits := loopHits(); hits != 0 {
+		t.Fatalf("Wrong number of hot set hits with PolicyLRU. 0 expected, but %v got", hits)
+	}
+	if hits := loopHits(lrucache.WithPolicy[int, int](lrucache.Policy2Q)); hits != 48 {
+		t.Fatalf("Wrong number of hot set hits with Policy2Q. 48 expected, but %v got", hits)
+	}
+}
@@ -0,0 +1,89 @@
+package lrucache
+
+import "container/list"
+
+// The entries of a cache using WithPromotionThreshold form two segments of the queue: the protected entries,
+// which have been promoted, at the head, and the probationary entries behind them, the first of which is
+// LruCache.probation. Each segment is ordered from the most recently used to the least recently used.
+
+// protectedMax returns the maximum size of the protected segment, which leaves at least a quarter of maxSize,
+// rounded up, to the probationary segment.
+func (cache *LruCache[K, V]) protectedMax() uint {
+	return cache.maxSize - cache.maxSize/4 - min(cache.maxSize%4, 1)
+}
+
+// pushProbationary adds entry to the head of the probationary segment and returns its element.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) pushProbationary(entry *entry[K, V]) (element *list.Element) {
+	if cache.probation != nil {
+		element = cache.l.InsertBefore(entry, cache.probation)
+	} else {
+		element = cache.l.PushBack(entry)
+	}
+	entry.probationary = true
+	cache.probation = element
+	return
+}
+
+// segmentUse moves element to the head of its segment. found is whether it was found by a get, which counts
+// toward its promotion. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) segmentUse(element *list.Element, found bool) {
+	entry := element.Value.(*entry[K, V])
+	if !entry.probationary {
+		cache.l.MoveToFront(element)
+		return
+	}
+	if found {
+		entry.probationHits++
+	}
+	if entry.probationHits >= cache.promotionThreshold {
+		cache.promote(element)
+	} else if element != cache.probation {
+		cache.l.MoveBefore(element, cache.probation)
+		cache.probation = element
+	}
+}
+
+// promote moves the probationary element to the head of the queue, demoting the least recently used
+// protected entries if the protected segment becomes too large. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) promote(element *list.Element) {
+	entry := element.Value.(*entry[K, V])
+	if element == cache.probation {
+		cache.probation = element.Next()
+	}
+	entry.probationary, entry.probationHits = false, 0
+	cache.protectedSize += entry.size
+	cache.l.MoveToFront(element)
+	cache.demote()
+}
+
+// demote moves the least recently used protected entries to the head of the probationary segment until the
+// protected segment fits protectedMax, or only the most recently used entry is left in it.
+// Demoted entries must be found promotionThreshold times again to be promoted. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) demote() {
+	for cache.protectedSize > cache.protectedMax() {
+		tail := cache.l.Back()
+		if cache.probation != nil {
+			tail = cache.probation.Prev()
+		}
+		if tail == nil || tail == cache.l.Front() {
+			return
+		}
+		entry := tail.Value.(*entry[K, V])
+		entry.probationary = true
+		cache.protectedSize -= entry.size
+		cache.probation = tail
+	}
+}
+
+// leaveSegment removes element, which is about to be removed from the queue, from its segment.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) leaveSegment(element *list.Element) {
+	entry := element.Value.(*entry[K, V])
+	if element == cache.probation {
+		cache.probation = element.Next()
+	}
+	if !entry.probationary {
+		cache.protectedSize -= entry.size
+	}
+}
@@ -0,0 +1,57 @@
+package lrucache
+
+// Reason is why an entry was removed. See WithEntryRemovedReason.
+type Reason int
+
+const (
+	// ReasonEvicted is for an entry evicted to make space.
+	ReasonEvicted Reason = iota
+	// ReasonReplaced is for a value replaced by a put of the same key.
+	ReasonReplaced
+	// ReasonRemoved is for an entry removed by Remove or OpRemove, or because it depends on a removed entry
+	// (see PutWithDeps).
+	ReasonRemoved
+	// ReasonExpired is for an entry which expired, see PutWithTTL.
+	ReasonExpired
+	// ReasonCleared is for an entry removed by Clear or OpClear.
+	ReasonCleared
+	// ReasonDiscarded is for a value created by GetEnsure and the like, which was never cached because
+	// another value for the key had been put meanwhile.
+	ReasonDiscarded
+	// ReasonCollected is for an entry whose value has been reclaimed by the garbage collector, see WithCollected.
+	ReasonCollected
+)
+
+// String returns the name of reason, such as "evicted".
+func (reason Reason) String() string {
+	switch reason {
+	case ReasonEvicted:
+		return "evicted"
+	case ReasonReplaced:
+		return "replaced"
+	case ReasonRemoved:
+		return "removed"
+	case ReasonExpired:
+		return "expired"
+	case ReasonCleared:
+		return "cleared"
+	case ReasonDiscarded:
+		return "discarded"
+	case ReasonCollected:
+		return "collected"
+	default:
+		return "unknown"
+	}
+}
+
+// EntryRemovedReason is the function called for entries that have been removed, like EntryRemoved,
+// with the reason of the removal.
+type EntryRemovedReason[K comparable, V any] func(key K, oldValue, newValue V, reason Reason)
+
+// WithEntryRemovedReason makes the cache call entryRemoved every time an entry was removed, with the reason.
+// It is called right after the EntryRemoved function passed to New, if any.
+func WithEntryRemovedReason[K comparable, V any](entryRemoved EntryRemovedReason[K, V]) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.entryRemovedReason = entryRemoved
+	}
+}
@@ -0,0 +1,32 @@
+package lrucache_test
+
+import (
+	"fmt"
+	"github.com/mkch/lrucache"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEntryRemovedReason(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var removed []string
+	cache := lrucache.New(3, nil, lrucache.WithClock[string, int](clock.Now),
+		lrucache.WithEntryRemovedReason(func(key string, oldValue, newValue int, reason lrucache.Reason) {
+			removed = append(removed, fmt.Sprintf("%v:%v", key, reason))
+		}))
+	cache.Put("a", 1)
+	cache.Put("b", 2)
+	cache.Put("c", 3)
+	cache.Put("d", 4) // Evicts "a".
+	cache.Put("b", 20)
+	cache.Remove("c")
+	cache.PutWithTTL("e", 5, 1, time.Minute)
+	clock.Advance(time.Minute)
+	cache.Get("e")
+	cache.Clear()
+	expected := []string{"a:evicted", "b:replaced", "c:removed", "e:expired", "d:cleared", "b:cleared"}
+	if !reflect.DeepEqual(removed, expected) {
+		t.Fatalf("Wrong removals reported to EntryRemovedReason. %v expected, but %v got", expected, removed)
+	}
+}
@@ -0,0 +1,99 @@
+package lrucache
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// ShardedLruCache partitions keys across independent LruCache shards, each with its own mutex,
+// to reduce lock contention under high concurrency.
+// The order of the queue and eviction are per shard, so the cache as a whole is only approximately LRU:
+// an entry may be evicted from a full shard while older entries remain in other shards.
+type ShardedLruCache[K comparable, V any] struct {
+	shards []*LruCache[K, V]
+	hash   func(key K) uint64
+}
+
+// NewSharded creates a sharded LRU cache of n shards, each created by New with maxSize/n
+// and the same entryRemoved and options. NewSharded panics if maxSize is less than n, which would leave shards of size 0.
+// hash returns the hash of a key which selects its shard, and must return the same value for equal keys.
+// If hash is nil, the FNV-1a hash of the key formatted by fmt.Sprint is used, which is stable but slow.
+func NewSharded[K comparable, V any](n int, maxSize uint, hash func(key K) uint64, entryRemoved EntryRemoved[K, V], options ...Option[K, V]) *ShardedLruCache[K, V] {
+	if n <= 0 {
+		panic("Invalid shard count")
+	}
+	if maxSize < uint(n) {
+		panic(fmt.Sprintf("Invalid cache size %v for %v shards", maxSize, n))
+	}
+	if hash == nil {
+		hash = hashSprint[K]
+	}
+	cache := &ShardedLruCache[K, V]{shards: make([]*LruCache[K, V], n), hash: hash}
+	for i := range cache.shards {
+		cache.shards[i] = New(maxSize/uint(n), entryRemoved, options...)
+	}
+	return cache
+}
+
+// hashSprint returns the FNV-1a hash of key formatted by fmt.Sprint.
+func hashSprint[K comparable](key K) uint64 {
+	h := fnv.New64a()
+	fmt.Fprint(h, key)
+	return h.Sum64()
+}
+
+// Shard returns the shard of key.
+func (cache *ShardedLruCache[K, V]) Shard(key K) *LruCache[K, V] {
+	return cache.shards[cache.hash(key)%uint64(len(cache.shards))]
+}
+
+// Get calls Get of the shard of key.
+func (cache *ShardedLruCache[K, V]) Get(key K) (value V, ok bool) {
+	return cache.Shard(key).Get(key)
+}
+
+// GetEnsure calls GetEnsure of the shard of key.
+func (cache *ShardedLruCache[K, V]) GetEnsure(key K, create CreateEntry[K, V]) (value V) {
+	return cache.Shard(key).GetEnsure(key, create)
+}
+
+// Put calls Put of the shard of key.
+func (cache *ShardedLruCache[K, V]) Put(key K, value V) (oldValue V, replaced bool) {
+	return cache.Shard(key).Put(key, value)
+}
+
+// PutSize calls PutSize of the shard of key.
+func (cache *ShardedLruCache[K, V]) PutSize(key K, value V, size uint) (oldValue V, replaced bool) {
+	return cache.Shard(key).PutSize(key, value, size)
+}
+
+// Remove calls Remove of the shard of key.
+func (cache *ShardedLruCache[K, V]) Remove(key K) (value V, ok bool) {
+	return cache.Shard(key).Remove(key)
+}
+
+// MaxSize returns the sum of the maximum sizes of all shards.
+func (cache *ShardedLruCache[K, V]) MaxSize() (maxSize uint) {
+	for _, shard := range cache.shards {
+		maxSize += shard.MaxSize()
+	}
+	return
+}
+
+// Size returns the sum of the sizes of all shards.
+// The shards are read one by one, so the result is not a snapshot under concurrent modification.
+func (cache *ShardedLruCache[K, V]) Size() (size uint) {
+	for _, shard := range cache.shards {
+		size += shard.Size()
+	}
+	return
+}
+
+// Len returns the number of entries in all shards.
+// The shards are read one by one, so the result is not a snapshot under concurrent modification.
+func (cache *ShardedLruCache[K, V]) Len() (n int) {
+	for _, shard := range cache.shards {
+		n += shard.Len()
+	}
+	return
+}
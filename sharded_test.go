@@ -0,0 +1,81 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"testing"
+)
+
+func TestSharded(t *testing.T) {
+	var removed []int
+	cache := lrucache.NewSharded(4, 8, func(key int) uint64 { return uint64(key) }, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	})
+	if maxSize := cache.MaxSize(); maxSize != 8 {
+		t.Fatalf("Wrong value returned by ShardedLruCache.MaxSize. 8 expected, but %v returned", maxSize)
+	}
+	for i := 0; i < 8; i++ {
+		cache.Put(i, i*10)
+	}
+	if n, size := cache.Len(), cache.Size(); n != 8 || size != 8 {
+		t.Fatalf("Wrong value returned by ShardedLruCache.Len and ShardedLruCache.Size. 8, 8 expected, but %v, %v returned", n, size)
+	}
+	cache.Put(8, 80) // Evicts 0 from the shard of 0, 4 and 8.
+	if len(removed) != 1 || removed[0] != 0 {
+		t.Fatalf("Wrong removed keys. [0] expected, but %v got", removed)
+	}
+	if value, ok := cache.Get(4); !ok || value != 40 {
+		t.Fatalf("Wrong value returned by ShardedLruCache.Get. 40, true expected, but %v, %v returned", value, ok)
+	}
+	if value := cache.GetEnsure(9, func(key int) (int, uint) { return 90, 1 }); value != 90 {
+		t.Fatalf("Wrong value returned by ShardedLruCache.GetEnsure. 90 expected, but %v returned", value)
+	}
+	if value, ok := cache.Remove(9); !ok || value != 90 {
+		t.Fatalf("Wrong value returned by ShardedLruCache.Remove. 90, true expected, but %v, %v returned", value, ok)
+	}
+	if cache.Shard(1) != cache.Shard(5) || cache.Shard(1) == cache.Shard(2) {
+		t.Fatal("Wrong value returned by ShardedLruCache.Shard")
+	}
+}
+
+func TestShardedDefaultHash(t *testing.T) {
+	cache := lrucache.NewSharded[string, int](4, 100, nil, nil)
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		cache.Put(key, len(key))
+	}
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		if value, ok := cache.Get(key); !ok || value != 1 {
+			t.Fatalf("Wrong value returned by ShardedLruCache.Get(%q). 1, true expected, but %v, %v returned", key, value, ok)
+		}
+	}
+}
+
+func BenchmarkGetPutParallel(b *testing.B) {
+	cache := lrucache.New[int, int](1024, nil)
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, ok := cache.Get(i % 2048); !ok {
+				cache.Put(i%2048, i)
+			}
+		}
+	})
+}
+
+func BenchmarkGetPutParallelSharded(b *testing.B) {
+	cache := lrucache.NewSharded[int, int](16, 1024, func(key int) uint64 { return uint64(key) }, nil)
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, ok := cache.Get(i % 2048); !ok {
+				cache.Put(i%2048, i)
+			}
+		}
+	})
+}
+
+func TestShardedTooSmall(t *testing.T) {
+	defer func() {
+		if r := recover(); r != "Invalid cache size 3 for 4 shards" {
+			t.Fatalf("Wrong panic. Invalid cache size 3 for 4 shards expected, but %v got", r)
+		}
+	}()
+	lrucache.NewSharded[int, int](4, 3, nil, nil)
+}
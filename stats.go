@@ -0,0 +1,51 @@
+package lrucache
+
+import "sync/atomic"
+
+// Stats is the statistics of a cache. See LruCache.Stats.
+type Stats struct {
+	// Lookups finding a value, by Get, GetLocal, GetNoPromote, GetMulti, GetMultiWithSize, GetOrPut,
+	// GetEnsure and the like, GetEnsureAsync and GetEnsureNeg.
+	Hits         uint64
+	Misses       uint64 // Lookups finding nothing, by the same methods. A miss served by the cache passed to WithReadFallback is still a miss.
+	Evictions    uint64 // Entries evicted to make space.
+	Replacements uint64 // Values replaced by a put of the same key.
+}
+
+// stats is the atomic counters of Stats.
+type stats struct {
+	hits, misses, evictions, replacements atomic.Uint64
+}
+
+// Stats returns the statistics counted since the cache was created or ResetStats was called.
+// The counters are read atomically one by one, without the mutex, so they may be slightly inconsistent
+// with each other while the cache is in use.
+func (cache *LruCache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:         cache.stats.hits.Load(),
+		Misses:       cache.stats.misses.Load(),
+		Evictions:    cache.stats.evictions.Load(),
+		Replacements: cache.stats.replacements.Load(),
+	}
+}
+
+// ResetStats sets all the counters of Stats to zero.
+func (cache *LruCache[K, V]) ResetStats() {
+	cache.stats.hits.Store(0)
+	cache.stats.misses.Store(0)
+	cache.stats.evictions.Store(0)
+	cache.stats.replacements.Store(0)
+}
+
+// Utilization returns the size of the cache divided by its maximum size, and the average entry size,
+// which is 0 for an empty cache. Both are computed from one snapshot taken with the read lock held.
+// Utilization may exceed 1 if evictions are vetoed or an entry is larger than the maximum size.
+func (cache *LruCache[K, V]) Utilization() (utilization, averageEntrySize float64) {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	utilization = float64(cache.size) / float64(cache.maxSize)
+	if len(cache.m) > 0 {
+		averageEntrySize = float64(cache.size) / float64(len(cache.m))
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	cache := lrucache.New[int, int](2, nil)
+	cache.Put(1, 1)
+	cache.Get(1)
+	cache.Get(2)
+	cache.GetEnsure(2, func(key int) (int, uint) { return 2, 1 }) // Missed by Get.
+	cache.Put(1, 10)
+	cache.Put(3, 3) // Evicts 2.
+	cache.Remove(1) // Not an eviction.
+	expected := lrucache.Stats{Hits: 1, Misses: 2, Evictions: 1, Replacements: 1}
+	if stats := cache.Stats(); stats != expected {
+		t.Fatalf("Wrong value returned by LruCache.Stats. %+v expected, but %+v returned", expected, stats)
+	}
+	cache.ResetStats()
+	cache.Get(3)
+	if stats := cache.Stats(); stats != (lrucache.Stats{Hits: 1}) {
+		t.Fatalf("Wrong value returned by LruCache.Stats. {Hits:1} expected, but %+v returned", stats)
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	cache := lrucache.New[int, int](20, nil)
+	if utilization, average := cache.Utilization(); utilization != 0 || average != 0 {
+		t.Fatalf("Wrong value returned by LruCache.Utilization. 0, 0 expected, but %v, %v returned", utilization, average)
+	}
+	cache.PutSize(1, 1, 2)
+	cache.PutSize(2, 2, 3)
+	cache.PutSize(3, 3, 10)
+	if utilization, average := cache.Utilization(); utilization != 0.75 || average != 5 {
+		t.Fatalf("Wrong value returned by LruCache.Utilization. 0.75, 5 expected, but %v, %v returned", utilization, average)
+	}
+}
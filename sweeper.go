@@ -0,0 +1,112 @@
+package lrucache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// sweepBatch is the maximum number of entries checked by the sweeper with the mutex locked at a time.
+const sweepBatch = 64
+
+// sweeper is the background goroutine started by StartSweeper.
+type sweeper struct {
+	mutex sync.Mutex
+	stop  chan struct{} // Closed to stop the goroutine. nil if not started.
+	done  chan struct{} // Closed when the goroutine has returned.
+}
+
+// StartSweeper starts a goroutine which removes the expired entries, see PutWithTTL, WithDefaultTTL,
+// WithMaxIdle and WithCollected, every interval, so that they do not hold their size until looked up.
+// The EntryRemoved function is called for them with ReasonExpired or ReasonCollected, as if they were looked up.
+// The entries are checked from the least recently used, a few at a time, unlocking the mutex in between,
+// so a sweep of a large cache does not block other methods for long.
+// A sweeper already started is stopped first, as StopSweeper does. Call StopSweeper to stop it.
+// StartSweeper panics if interval is not positive.
+func (cache *LruCache[K, V]) StartSweeper(interval time.Duration) {
+	if interval <= 0 {
+		panic("Invalid sweep interval")
+	}
+	cache.sweeper.mutex.Lock()
+	defer cache.sweeper.mutex.Unlock()
+	cache.stopSweeper()
+	stop, done := make(chan struct{}), make(chan struct{})
+	cache.sweeper.stop, cache.sweeper.done = stop, done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				cache.sweep(stop)
+			}
+		}
+	}()
+}
+
+// StopSweeper stops the goroutine started by StartSweeper and waits for it to return.
+// It does nothing if no sweeper is running.
+// The EntryRemoved function and the other callbacks run by the sweeper for the entries it removes must not call
+// StopSweeper or StartSweeper, which would wait for the sweeper to return and never return.
+func (cache *LruCache[K, V]) StopSweeper() {
+	cache.sweeper.mutex.Lock()
+	defer cache.sweeper.mutex.Unlock()
+	cache.stopSweeper()
+}
+
+// stopSweeper does the work of StopSweeper. Must be called with the sweeper mutex locked.
+func (cache *LruCache[K, V]) stopSweeper() {
+	if cache.sweeper.stop == nil {
+		return
+	}
+	close(cache.sweeper.stop)
+	<-cache.sweeper.done
+	cache.sweeper.stop, cache.sweeper.done = nil, nil
+}
+
+// sweep removes the expired entries, sweepBatch entries at a time, until all entries are checked,
+// the entry to check next is removed by others in between, or stop is closed.
+func (cache *LruCache[K, V]) sweep(stop <-chan struct{}) {
+	var next *list.Element
+	for first := true; first || next != nil; first = false {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		var removals []removal[K, V]
+		cache.mutex.Lock()
+		if !cache.expiring {
+			cache.mutex.Unlock()
+			return
+		}
+		if first {
+			next = cache.l.Back()
+		} else if !cache.present(next) {
+			next = nil
+		}
+		for i := 0; i < sweepBatch && next != nil; i++ {
+			element := next
+			next = element.Prev()
+			entry := element.Value.(*entry[K, V])
+			if !cache.expired(entry) {
+				continue
+			}
+			removals = append(removals, cache.evict(element, cache.expiredReason(entry))...)
+			// The entry to check next may have depended on the removed one.
+			if next != nil && !cache.present(next) {
+				next = nil
+			}
+		}
+		cache.mutex.Unlock()
+		cache.notify(removals)
+	}
+}
+
+// present returns whether element is still in the cache. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) present(element *list.Element) bool {
+	return cache.m[element.Value.(*entry[K, V]).k] == element
+}
@@ -0,0 +1,68 @@
+package lrucache_test
+
+import (
+	"fmt"
+	"github.com/mkch/lrucache"
+	"testing"
+	"time"
+)
+
+func TestSweeper(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	removed := make(chan string, 1000)
+	cache := lrucache.New(1000, nil, lrucache.WithClock[string, int](clock.Now),
+		lrucache.WithEntryRemovedReason(func(key string, oldValue, newValue int, reason lrucache.Reason) {
+			removed <- fmt.Sprintf("%v:%v", key, reason)
+		}))
+	// More entries than checked at a time.
+	for i := 0; i < 200; i++ {
+		cache.PutWithTTL(fmt.Sprint(i), i, 1, time.Minute)
+	}
+	cache.PutWithTTL("later", 1, 1, 2*time.Minute)
+	cache.Put("never", 1)
+
+	cache.StartSweeper(time.Millisecond)
+	defer cache.StopSweeper()
+	clock.Advance(time.Minute)
+	swept := make(map[string]bool)
+	for len(swept) < 200 {
+		select {
+		case key := <-removed:
+			swept[key] = true
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Expired entries not swept. 200 removals expected, but %v got", len(swept))
+		}
+	}
+	for i := 0; i < 200; i++ {
+		if key := fmt.Sprintf("%v:expired", i); !swept[key] {
+			t.Fatalf("Wrong removals reported to EntryRemovedReason. %v expected, but not got", key)
+		}
+	}
+	if size := cache.Size(); size != 2 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 2 expected, but %v returned", size)
+	}
+
+	cache.StopSweeper()
+	clock.Advance(time.Minute)
+	time.Sleep(10 * time.Millisecond)
+	if size := cache.Size(); size != 2 {
+		t.Fatalf("Wrong value returned by LruCache.Size after StopSweeper. 2 expected, but %v returned", size)
+	}
+	select {
+	case key := <-removed:
+		t.Fatalf("Entry removed after StopSweeper: %v", key)
+	default:
+	}
+
+	cache.StartSweeper(time.Millisecond)
+	select {
+	case key := <-removed:
+		if key != "later:expired" {
+			t.Fatalf("Wrong removal reported to EntryRemovedReason. later:expired expected, but %v got", key)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expired entry not swept after restarting the sweeper")
+	}
+	cache.StopSweeper()
+	cache.StopSweeper() // Does nothing.
+}
@@ -0,0 +1,98 @@
+package lrucache
+
+// tinyLFUDepth is the number of rows of the count-min sketch of WithTinyLFU.
+const tinyLFUDepth = 4
+
+// tinyLFUMaxCount is the maximum value of a counter of the count-min sketch of WithTinyLFU.
+const tinyLFUMaxCount = 15
+
+// WithTinyLFU protects frequently used entries from being flushed out by keys used only once, such as a scan.
+// The access frequencies of keys, including keys not in the cache, are estimated by a count-min sketch,
+// and a new key is admitted only if its frequency is higher than that of the entry it would evict.
+// A rejected value is not cached, and is passed to the EntryRemoved function as oldValue, with ReasonDiscarded
+// if WithEntryRemovedReason is used. Keys are counted each time they are looked up or put.
+// hash returns the hash of a key and must return the same value for equal keys. If hash is nil,
+// the FNV-1a hash of the key formatted by fmt.Sprint is used, which is stable but slow.
+// n is the number of counters in each row of the sketch, rounded up to a power of 2, and is typically
+// several times the number of entries expected when the cache is full. The counters are halved
+// after 10*n counts, so that old accesses are gradually forgotten.
+// Lookups take the write lock even if WithApproximateLRU is used.
+func WithTinyLFU[K comparable, V any](hash func(key K) uint64, n int) Option[K, V] {
+	if n <= 0 {
+		panic("Invalid counter count")
+	}
+	if hash == nil {
+		hash = hashSprint[K]
+	}
+	width := 1
+	for width < n {
+		width <<= 1
+	}
+	return func(cache *LruCache[K, V]) {
+		sketch := &tinyLFU[K]{hash: hash, mask: uint64(width - 1), sampleSize: 10 * width}
+		for i := range sketch.rows {
+			sketch.rows[i] = make([]uint8, width)
+		}
+		cache.tinyLFU = sketch
+	}
+}
+
+// tinyLFU is the count-min sketch of WithTinyLFU.
+type tinyLFU[K comparable] struct {
+	hash       func(key K) uint64
+	rows       [tinyLFUDepth][]uint8
+	mask       uint64
+	additions  int // Counts since the last aging.
+	sampleSize int // Counts between agings.
+}
+
+// index returns the index of the counter of hash h in row i.
+func (sketch *tinyLFU[K]) index(h uint64, i int) uint64 {
+	return (h + uint64(i)*(h>>32|h<<32|1)) & sketch.mask
+}
+
+// record counts an access of key.
+func (sketch *tinyLFU[K]) record(key K) {
+	h := sketch.hash(key)
+	for i := range sketch.rows {
+		if counter := &sketch.rows[i][sketch.index(h, i)]; *counter < tinyLFUMaxCount {
+			*counter++
+		}
+	}
+	if sketch.additions++; sketch.additions >= sketch.sampleSize {
+		sketch.age()
+	}
+}
+
+// estimate returns the estimated access frequency of key.
+func (sketch *tinyLFU[K]) estimate(key K) uint8 {
+	h := sketch.hash(key)
+	min := uint8(tinyLFUMaxCount)
+	for i := range sketch.rows {
+		if counter := sketch.rows[i][sketch.index(h, i)]; counter < min {
+			min = counter
+		}
+	}
+	return min
+}
+
+// age halves all counters.
+func (sketch *tinyLFU[K]) age() {
+	for i := range sketch.rows {
+		for j := range sketch.rows[i] {
+			sketch.rows[i][j] >>= 1
+		}
+	}
+	sketch.additions /= 2
+}
+
+// admit returns whether a new entry for key of size should be added, rather than rejected to keep the
+// entry it would evict. Must be called with the mutex locked.
+func (cache *LruCache[K, V]) admit(key K, size uint) bool {
+	cache.tinyLFU.record(key)
+	if cache.size+size <= cache.maxSize {
+		return true
+	}
+	victim := cache.victim()
+	return victim == nil || cache.tinyLFU.estimate(key) > cache.tinyLFU.estimate(victim.Value.(*entry[K, V]).k)
+}
@@ -0,0 +1,55 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"testing"
+)
+
+// scanHits replays a hot set of 8 keys interleaved with a scan of keys used once on a cache of size 10,
+// getting each key and putting it on a miss, and returns the number of hot set hits in the last round.
+func scanHits(options ...lrucache.Option[int, int]) (hits int) {
+	cache := lrucache.New(10, nil, options...)
+	scan := 1000
+	for round := 0; round < 20; round++ {
+		hits = 0
+		for hot := 0; hot < 8; hot++ {
+			if _, ok := cache.Get(hot); ok {
+				hits++
+			} else {
+				cache.Put(hot, hot)
+			}
+			for i := 0; i < 2; i++ {
+				if _, ok := cache.Get(scan); !ok {
+					cache.Put(scan, scan)
+				}
+				scan++
+			}
+		}
+	}
+	return
+}
+
+func TestTinyLFU(t *testing.T) {
+	if hits := scanHits(); hits != 0 {
+		t.Fatalf("Wrong number of hot set hits without TinyLFU. 0 expected, but %v got", hits)
+	}
+	if hits := scanHits(lrucache.WithTinyLFU[int, int](func(key int) uint64 { return uint64(key) * 0x9E3779B97F4A7C15 }, 64)); hits != 8 {
+		t.Fatalf("Wrong number of hot set hits with TinyLFU. 8 expected, but %v got", hits)
+	}
+}
+
+func TestTinyLFURejected(t *testing.T) {
+	var removed []int
+	cache := lrucache.New(1, func(key, oldValue, newValue int) {
+		removed = append(removed, key)
+	}, lrucache.WithTinyLFU[int, int](nil, 16))
+	cache.Put(1, 1)
+	cache.Get(1)
+	cache.Put(2, 2) // Rejected.
+	if len(removed) != 1 || removed[0] != 2 {
+		t.Fatalf("Wrong removed keys. [2] expected, but %v got", removed)
+	}
+	if _, ok := cache.Get(1); !ok {
+		t.Fatal("Wrong value returned by LruCache.Get. 1 expected to be found")
+	}
+}
@@ -0,0 +1,86 @@
+package lrucache
+
+import (
+	"container/list"
+	"time"
+)
+
+// WithClock makes the cache read the current time from now instead of time.Now, e.g. to control
+// the expiration of entries put by PutWithTTL in tests.
+func WithClock[K comparable, V any](now func() time.Time) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.now = now
+	}
+}
+
+// WithDefaultTTL makes the entries put without a ttl of their own, by PutSize, GetEnsure etc., expire after ttl.
+// See PutWithTTL. Zero ttl, the default, means never expire.
+func WithDefaultTTL[K comparable, V any](ttl time.Duration) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.defaultTTL = ttl
+		cache.expiring = cache.expiring || ttl > 0
+	}
+}
+
+// WithMaxIdle makes entries expire when they have not been used for d, however recently they were put,
+// so an entry used often never expires unless it has a ttl of its own, see PutWithTTL.
+// An entry is used when it is put or replaced, found by Get and the other getting methods except Peek and GetNoPromote,
+// or touched by Touch. An idle entry is treated like an expired one.
+// Lookups take the write lock even if WithApproximateLRU is used.
+func WithMaxIdle[K comparable, V any](d time.Duration) Option[K, V] {
+	return func(cache *LruCache[K, V]) {
+		cache.maxIdle = d
+		cache.expiring = cache.expiring || d > 0
+	}
+}
+
+// PutWithTTL does similar work as PutSize except the entry expires after ttl, or never if ttl is zero,
+// regardless of WithDefaultTTL.
+// An expired entry is treated as a miss: it is removed, and the EntryRemoved function is called with the zero newValue,
+// by the first Get, GetEnsure or other method looking it up. Until then it is still counted by Size.
+// When space is needed, an expired entry among the last entries of the queue is evicted before any other entry,
+// even one less recently used or vetoed by the WithEvictionVeto function.
+// Entries put by PutSize, and entries replaced by PutSize, never expire unless WithDefaultTTL is used.
+func (cache *LruCache[K, V]) PutWithTTL(key K, value V, size uint, ttl time.Duration) (oldValue V, replaced bool) {
+	cache.validateKey(key)
+	cache.operations.Add(1)
+	var removals []removal[K, V]
+	cache.mutex.Lock()
+	oldValue, replaced, removals = cache.putSize(key, value, size, 0)
+	if element := cache.m[key]; element != nil {
+		element.Value.(*entry[K, V]).expires = cache.expiry(ttl)
+	}
+	cache.expiring = cache.expiring || ttl > 0
+	cache.mutex.Unlock()
+	cache.notify(removals)
+	return
+}
+
+// lookup returns the element of key, or nil if not found. An expired entry is removed and nil is returned.
+// Must be called with the mutex locked.
+func (cache *LruCache[K, V]) lookup(key K) (element *list.Element, removals []removal[K, V]) {
+	if element = cache.m[key]; element != nil && cache.expired(element.Value.(*entry[K, V])) {
+		removals = cache.evict(element, cache.expiredReason(element.Value.(*entry[K, V])))
+		element = nil
+	}
+	return
+}
+
+// expiry returns the expiry time of an entry put now with ttl, or the zero time if ttl is zero.
+func (cache *LruCache[K, V]) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return cache.now().Add(ttl)
+}
+
+// expired returns whether entry has expired, or its value has been collected, see WithCollected.
+func (cache *LruCache[K, V]) expired(entry *entry[K, V]) bool {
+	if cache.collected != nil && cache.collected(entry.v) {
+		return true
+	}
+	if cache.maxIdle > 0 && cache.now().Sub(entry.used) >= cache.maxIdle {
+		return true
+	}
+	return !entry.expires.IsZero() && !cache.now().Before(entry.expires)
+}
@@ -0,0 +1,123 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock for WithClock which only moves when told to.
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (clock *fakeClock) Now() time.Time {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	return clock.now
+}
+
+func (clock *fakeClock) Advance(d time.Duration) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.now = clock.now.Add(d)
+}
+
+func TestPutWithTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var removed []string
+	cache := lrucache.New(10, func(key string, oldValue, newValue int) {
+		removed = append(removed, key)
+	}, lrucache.WithClock[string, int](clock.Now))
+	cache.PutWithTTL("a", 1, 1, time.Minute)
+	cache.PutWithTTL("b", 2, 1, 2*time.Minute)
+	cache.Put("c", 3)
+	cache.PutWithTTL("d", 4, 1, time.Minute)
+	cache.Put("d", 40) // No longer expires.
+
+	clock.Advance(time.Minute)
+	if value, ok := cache.Get("a"); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+	if !reflect.DeepEqual(removed, []string{"d", "a"}) {
+		t.Fatalf("Wrong removed keys. [d a] expected, but %v got", removed)
+	}
+	for key, expected := range map[string]int{"b": 2, "c": 3, "d": 40} {
+		if value, ok := cache.Get(key); !ok || value != expected {
+			t.Fatalf("Wrong value returned by LruCache.Get(%q). %v, true expected, but %v, %v returned", key, expected, value, ok)
+		}
+	}
+
+	clock.Advance(time.Hour)
+	if cache.Contains("b") {
+		t.Fatal("Wrong value returned by LruCache.Contains. false expected for expired entry")
+	}
+	if value := cache.GetEnsure("b", func(key string) (int, uint) { return 20, 1 }); value != 20 {
+		t.Fatalf("Wrong value returned by LruCache.GetEnsure. 20 expected, but %v returned", value)
+	}
+	if size := cache.Size(); size != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Size. 3 expected, but %v returned", size)
+	}
+}
+
+func TestDefaultTTL(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var removed []string
+	cache := lrucache.New(3, func(key string, oldValue, newValue int) {
+		removed = append(removed, key)
+	}, lrucache.WithClock[string, int](clock.Now), lrucache.WithDefaultTTL[string, int](time.Minute))
+	cache.PutWithTTL("forever", 0, 1, 0)
+	cache.Put("a", 1)
+	clock.Advance(time.Second)
+	cache.PutWithTTL("b", 2, 1, time.Hour)
+
+	clock.Advance(time.Minute)
+	cache.Put("c", 3) // Evicts the expired "a" rather than the least recently used "forever".
+	if !reflect.DeepEqual(removed, []string{"a"}) {
+		t.Fatalf("Wrong removed keys. [a] expected, but %v got", removed)
+	}
+	for key, expected := range map[string]bool{"forever": true, "b": true, "c": true} {
+		if _, ok := cache.Get(key); ok != expected {
+			t.Fatalf("Wrong value returned by LruCache.Get(%q). %v expected, but %v returned", key, expected, ok)
+		}
+	}
+	clock.Advance(time.Minute)
+	if value, ok := cache.Get("c"); ok {
+		t.Fatalf("Wrong value returned by LruCache.Get. Nothing expected, but %v returned", value)
+	}
+}
+
+func TestMaxIdle(t *testing.T) {
+	clock := &fakeClock{now: time.Unix(0, 0)}
+	var removed []string
+	cache := lrucache.New(10, nil, lrucache.WithClock[string, int](clock.Now), lrucache.WithMaxIdle[string, int](time.Minute),
+		lrucache.WithEntryRemovedReason(func(key string, oldValue, newValue int, reason lrucache.Reason) {
+			removed = append(removed, key+":"+reason.String())
+		}))
+	cache.Put("used", 1)
+	cache.Put("touched", 2)
+	cache.Put("idle", 3)
+	cache.PutWithTTL("ttl", 4, 1, 2*time.Minute)
+	for i := 0; i < 4; i++ {
+		clock.Advance(40 * time.Second)
+		cache.Get("used")
+		cache.Touch("touched")
+		cache.Get("ttl")
+	}
+	if _, ok := cache.Get("idle"); ok {
+		t.Fatal("Wrong value returned by LruCache.Get. Nothing expected for idle entry")
+	}
+	if !reflect.DeepEqual(removed, []string{"ttl:expired", "idle:expired"}) {
+		t.Fatalf("Wrong removed keys. [ttl:expired idle:expired] expected, but %v got", removed)
+	}
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []string{"touched", "used"}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [touched used] expected, but %v returned", keys)
+	}
+	clock.Advance(time.Minute)
+	if _, ok := cache.Peek("used"); ok {
+		t.Fatal("Wrong value returned by LruCache.Peek. Nothing expected for idle entry")
+	}
+}
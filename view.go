@@ -0,0 +1,154 @@
+package lrucache
+
+// View is an immutable snapshot of a LruCache. See LruCache.View.
+// Reading a View never changes it or the cache it was taken from.
+type View[K comparable, V any] struct {
+	entries []Entry[K, V] // From the most recently used to the least recently used.
+	m       map[K]*Entry[K, V]
+	size    uint
+}
+
+// View returns a snapshot of the cache. Later changes of the cache are not reflected by the snapshot.
+// All entries are copied with the read lock held, which takes O(n) time and memory.
+// Values are shared, not copied.
+func (cache *LruCache[K, V]) View() *View[K, V] {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	view := &View[K, V]{entries: make([]Entry[K, V], 0, cache.l.Len()), m: make(map[K]*Entry[K, V], cache.l.Len()), size: cache.size}
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		// Only the fields written under the write lock are copied. See WithApproximateLRU.
+		entry := element.Value.(*entry[K, V])
+		view.entries = append(view.entries, Entry[K, V]{entry.k, entry.v, entry.size})
+	}
+	for i := range view.entries {
+		view.m[view.entries[i].Key] = &view.entries[i]
+	}
+	return view
+}
+
+// Get returns the value for key and true, or the zero value and false if no value is found.
+func (view *View[K, V]) Get(key K) (value V, ok bool) {
+	if entry := view.m[key]; entry != nil {
+		return entry.Value, true
+	}
+	return
+}
+
+// Keys returns the keys from the most recently used to the least recently used.
+func (view *View[K, V]) Keys() []K {
+	keys := make([]K, len(view.entries))
+	for i := range view.entries {
+		keys[i] = view.entries[i].Key
+	}
+	return keys
+}
+
+// Range calls f for each entry from the most recently used to the least recently used, until f returns false.
+func (view *View[K, V]) Range(f func(key K, value V) bool) {
+	for i := range view.entries {
+		if !f(view.entries[i].Key, view.entries[i].Value) {
+			return
+		}
+	}
+}
+
+// Size returns the size of the cache when the snapshot was taken.
+func (view *View[K, V]) Size() uint {
+	return view.size
+}
+
+// Keys returns the keys from the most recently used to the least recently used.
+// The result is a copy taken with the read lock held, not updated by later changes of the cache.
+// Expired entries are not included.
+func (cache *LruCache[K, V]) Keys() []K {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	keys := make([]K, 0, cache.l.Len())
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		if entry := element.Value.(*entry[K, V]); !cache.expired(entry) {
+			keys = append(keys, entry.k)
+		}
+	}
+	return keys
+}
+
+// Values returns the values from the most recently used to the least recently used.
+// The result is a copy taken with the read lock held, not updated by later changes of the cache.
+// Values are shared, not copied. Expired entries are not included.
+func (cache *LruCache[K, V]) Values() []V {
+	cache.mutex.RLock()
+	defer cache.mutex.RUnlock()
+	values := make([]V, 0, cache.l.Len())
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		if entry := element.Value.(*entry[K, V]); !cache.expired(entry) {
+			values = append(values, entry.v)
+		}
+	}
+	return values
+}
+
+// Range calls f for each entry from the most recently used to the least recently used, until f returns false.
+// The entries are copied with the read lock held, and f is called without holding the lock, so f may access the cache.
+// Changes made meanwhile, by f or others, are not seen by the iteration. Expired entries are skipped.
+func (cache *LruCache[K, V]) Range(f func(key K, value V) bool) {
+	cache.mutex.RLock()
+	entries := make([]Entry[K, V], 0, cache.l.Len())
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		if entry := element.Value.(*entry[K, V]); !cache.expired(entry) {
+			entries = append(entries, Entry[K, V]{entry.k, entry.v, entry.size})
+		}
+	}
+	cache.mutex.RUnlock()
+
+	for _, entry := range entries {
+		if !f(entry.Key, entry.Value) {
+			return
+		}
+	}
+}
+
+// RangeOldest does the same work as Range except the entries are visited from the least recently used
+// to the most recently used.
+func (cache *LruCache[K, V]) RangeOldest(f func(key K, value V) bool) {
+	cache.mutex.RLock()
+	entries := make([]Entry[K, V], 0, cache.l.Len())
+	for element := cache.l.Back(); element != nil; element = element.Prev() {
+		if entry := element.Value.(*entry[K, V]); !cache.expired(entry) {
+			entries = append(entries, Entry[K, V]{entry.k, entry.v, entry.size})
+		}
+	}
+	cache.mutex.RUnlock()
+
+	for _, entry := range entries {
+		if !f(entry.Key, entry.Value) {
+			return
+		}
+	}
+}
+
+// Entry is a cache entry.
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+	Size  uint
+}
+
+// SnapshotFunc returns the entries for which match returns true, from the most recently used to the least recently used.
+// The entries are copied with the read lock held, and match is called without holding the lock.
+// The result can be persisted with any encoding, e.g. to keep only durable entries across restarts.
+func (cache *LruCache[K, V]) SnapshotFunc(match func(key K, value V) bool) (entries []Entry[K, V]) {
+	cache.mutex.RLock()
+	all := make([]Entry[K, V], 0, cache.l.Len())
+	for element := cache.l.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*entry[K, V])
+		all = append(all, Entry[K, V]{entry.k, entry.v, entry.size})
+	}
+	cache.mutex.RUnlock()
+
+	for _, entry := range all {
+		if match(entry.Key, entry.Value) {
+			entries = append(entries, entry)
+		}
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package lrucache_test
+
+import (
+	"github.com/mkch/lrucache"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestView(t *testing.T) {
+	cache := lrucache.New[int, string](10, nil)
+	cache.Put(1, "1")
+	cache.PutSize(2, "2", 2)
+	cache.Put(3, "3")
+	view := cache.View()
+	cache.Put(4, "4")
+	cache.Remove(3)
+	cache.Get(1)
+
+	if size := view.Size(); size != 4 {
+		t.Fatalf("Wrong value returned by View.Size. 4 expected, but %v returned", size)
+	}
+	if keys := view.Keys(); !reflect.DeepEqual(keys, []int{3, 2, 1}) {
+		t.Fatalf("Wrong value returned by View.Keys. [3 2 1] expected, but %v returned", keys)
+	}
+	if value, _ := view.Get(3); value != "3" {
+		t.Fatalf("Wrong value returned by View.Get. \"3\" expected, but %v returned", value)
+	}
+	if value, ok := view.Get(4); ok {
+		t.Fatalf("Wrong value returned by View.Get. Nothing expected, but %v returned", value)
+	}
+	view.Get(1)
+	var keys []int
+	view.Range(func(key int, value string) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if !reflect.DeepEqual(keys, []int{3, 2}) {
+		t.Fatalf("Wrong keys iterated by View.Range. [3 2] expected, but %v got", keys)
+	}
+}
+
+func TestKeysValues(t *testing.T) {
+	cache := lrucache.New[int, string](10, nil)
+	cache.Put(1, "1")
+	cache.Put(2, "2")
+	cache.Put(3, "3")
+	cache.Get(1)
+	if keys := cache.Keys(); !reflect.DeepEqual(keys, []int{1, 3, 2}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [1 3 2] expected, but %v returned", keys)
+	}
+	if values := cache.Values(); !reflect.DeepEqual(values, []string{"1", "3", "2"}) {
+		t.Fatalf("Wrong value returned by LruCache.Values. [1 3 2] expected, but %v returned", values)
+	}
+}
+
+func TestRange(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	for i := 0; i < 6; i++ {
+		cache.Put(i, i)
+	}
+	var keys []int
+	cache.Range(func(key, value int) bool {
+		keys = append(keys, key)
+		cache.Remove(key) // Accessing the cache does not deadlock.
+		return len(keys) < 3
+	})
+	if !reflect.DeepEqual(keys, []int{5, 4, 3}) {
+		t.Fatalf("Wrong keys iterated by LruCache.Range. [5 4 3] expected, but %v got", keys)
+	}
+	if n := cache.Len(); n != 3 {
+		t.Fatalf("Wrong value returned by LruCache.Len. 3 expected, but %v returned", n)
+	}
+}
+
+func TestRangeOldest(t *testing.T) {
+	cache := lrucache.New[int, int](10, nil)
+	for i := 0; i < 6; i++ {
+		cache.Put(i, i)
+	}
+	cache.Get(0)
+	var keys []int
+	cache.RangeOldest(func(key, value int) bool {
+		keys = append(keys, key)
+		cache.Remove(key)
+		return len(keys) < 3
+	})
+	if !reflect.DeepEqual(keys, []int{1, 2, 3}) {
+		t.Fatalf("Wrong keys iterated by LruCache.RangeOldest. [1 2 3] expected, but %v got", keys)
+	}
+	if remaining := cache.Keys(); !reflect.DeepEqual(remaining, []int{0, 5, 4}) {
+		t.Fatalf("Wrong value returned by LruCache.Keys. [0 5 4] expected, but %v returned", remaining)
+	}
+}
+
+func TestSnapshotFunc(t *testing.T) {
+	cache := lrucache.New[string, int](10, nil)
+	cache.Put("durable:1", 1)
+	cache.Put("ephemeral:2", 2)
+	cache.PutSize("durable:3", 3, 3)
+	entries := cache.SnapshotFunc(func(key string, value int) bool {
+		return strings.HasPrefix(key, "durable:")
+	})
+	expected := []lrucache.Entry[string, int]{{"durable:3", 3, 3}, {"durable:1", 1, 1}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Wrong value returned by LruCache.SnapshotFunc. %v expected, but %v returned", expected, entries)
+	}
+}